@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKey is a static API key, only SHA-256 hash of the key is kept.
+type APIKey struct {
+	Name       string
+	KeySHA256  string // hex encoded SHA-256 hash of the key
+	Prefixes   []string
+	Operations []Operation
+}
+
+// APIKeyAuthenticator authenticates callers using static API keys.
+type APIKeyAuthenticator struct {
+	keys []apiKeyHash
+}
+
+type apiKeyHash struct {
+	hash      []byte
+	principal *Principal
+}
+
+// NewAPIKeyAuthenticator returns an Authenticator for given keys, error is
+// returned if any key hash is not a valid hex encoded SHA-256 hash.
+func NewAPIKeyAuthenticator(keys []APIKey) (*APIKeyAuthenticator, error) {
+	hashes := make([]apiKeyHash, 0, len(keys))
+	for _, key := range keys {
+		hash, err := hex.DecodeString(strings.TrimSpace(key.KeySHA256))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 hash for API key %q", key.Name)
+		}
+
+		hashes = append(hashes, apiKeyHash{
+			hash: hash,
+			principal: &Principal{
+				Subject:    key.Name,
+				Prefixes:   key.Prefixes,
+				Operations: key.Operations,
+			},
+		})
+	}
+
+	return &APIKeyAuthenticator{keys: hashes}, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(token string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(token))
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], key.hash) == 1 {
+			return key.principal, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
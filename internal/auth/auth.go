@@ -0,0 +1,152 @@
+// package auth provides authentication and authorization for APIs under /api.
+// Callers are authenticated using static API keys or JWTs, the resulting
+// Principal is then used by routes to authorize operations on image paths.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Operation is an action a Principal can be allowed to perform on images.
+type Operation string
+
+const (
+	OperationRead   Operation = "read"   // list images & read metadata
+	OperationUpload Operation = "upload" // add new images
+	OperationUpdate Operation = "update" // overwrite existing images
+	OperationDelete Operation = "delete" // delete images
+)
+
+// localsKey is the key under which authenticated Principal is stored in fiber.Ctx locals
+const localsKey = "auth.principal"
+
+// Principal is an authenticated caller along with its permissions.
+type Principal struct {
+	Subject    string      // API key name or JWT subject
+	Prefixes   []string    // image path prefixes the caller can access, empty allows all paths
+	Operations []Operation // operations the caller can perform
+}
+
+// Allows returns true if principal can perform `op` on image at `imagePath`.
+func (p *Principal) Allows(op Operation, imagePath string) bool {
+	if !slices.Contains(p.Operations, op) {
+		return false
+	}
+	if len(p.Prefixes) == 0 {
+		return true
+	}
+
+	// resolve `..` so that paths can't escape allowed prefixes
+	cleanPath := strings.TrimPrefix(path.Clean("/"+imagePath), "/")
+	if strings.HasSuffix(imagePath, "/") && cleanPath != "" {
+		cleanPath += "/" // keep directory prefixes e.g. when listing images
+	}
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(cleanPath, strings.TrimPrefix(prefix, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies credentials presented by a caller.
+type Authenticator interface {
+	// Authenticate returns the Principal for `token`. ErrInvalidCredentials is
+	// returned when token is not recognised by the Authenticator.
+	Authenticate(token string) (*Principal, error)
+}
+
+// Config defines the config for auth middleware.
+type Config struct {
+	// Authenticators are tried in order until one recognises the credentials
+	Authenticators []Authenticator
+
+	// Next defines a function to skip this middleware when returned true,
+	// e.g. for routes which verify their own signed tokens.
+	Next func(c *fiber.Ctx) bool
+}
+
+// New returns a middleware which authenticates every request using the configured
+// authenticators. Credentials are read from `Authorization: Bearer <token>`
+// or `X-API-Key` header.
+//
+// Unauthenticated requests are rejected with 401, authenticated Principal is
+// available to handlers using GetPrincipal.
+func New(config Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		token := c.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+			token = strings.TrimSpace(bearer)
+		}
+
+		if token == "" {
+			return unauthorized(c, ErrNoCredentials)
+		}
+
+		for _, authenticator := range config.Authenticators {
+			principal, err := authenticator.Authenticate(token)
+			if errors.Is(err, ErrInvalidCredentials) {
+				continue
+			} else if err != nil {
+				log.Warnw("failed to authenticate request", "path", c.Path(), "error", err.Error())
+				return unauthorized(c, ErrInvalidCredentials)
+			}
+
+			c.Locals(localsKey, principal)
+			return c.Next()
+		}
+
+		return unauthorized(c, ErrInvalidCredentials)
+	}
+}
+
+// GetPrincipal returns the authenticated Principal of the request, nil when auth is disabled.
+func GetPrincipal(c *fiber.Ctx) *Principal {
+	principal, _ := c.Locals(localsKey).(*Principal)
+	return principal
+}
+
+// Authorize returns true if the authenticated caller can perform `op` on image
+// at `path`, it is always true when auth is disabled. Use Forbidden to respond
+// to disallowed requests.
+func Authorize(c *fiber.Ctx, op Operation, path string) bool {
+	principal := GetPrincipal(c)
+	if principal == nil || principal.Allows(op, path) {
+		return true
+	}
+
+	log.Warnw("operation not allowed", "subject", principal.Subject, "operation", op, "path", path)
+	return false
+}
+
+// Forbidden sends `403 Forbidden` response for an operation which is not allowed.
+func Forbidden(c *fiber.Ctx, op Operation, path string) error {
+	return c.Status(http.StatusForbidden).JSON(fiber.Map{
+		"error":     "Operation not allowed",
+		"operation": op,
+		"path":      path,
+	})
+}
+
+func unauthorized(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="kriti-images"`)
+	return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
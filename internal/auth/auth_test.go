@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPrincipalAllows(t *testing.T) {
+	principal := &Principal{
+		Subject:    "cms",
+		Prefixes:   []string{"products/", "/banners/"},
+		Operations: []Operation{OperationUpload, OperationUpdate},
+	}
+
+	tests := []struct {
+		name    string
+		op      Operation
+		path    string
+		allowed bool
+	}{
+		{
+			name:    "allowed operation and prefix",
+			op:      OperationUpload,
+			path:    "products/shoe.jpg",
+			allowed: true,
+		},
+		{
+			name:    "prefix with leading slash",
+			op:      OperationUpdate,
+			path:    "banners/home.png",
+			allowed: true,
+		},
+		{
+			name:    "directory prefix",
+			op:      OperationUpload,
+			path:    "products/",
+			allowed: true,
+		},
+		{
+			name:    "operation not allowed",
+			op:      OperationDelete,
+			path:    "products/shoe.jpg",
+			allowed: false,
+		},
+		{
+			name:    "path outside prefixes",
+			op:      OperationUpload,
+			path:    "private/shoe.jpg",
+			allowed: false,
+		},
+		{
+			name:    "path escaping prefix",
+			op:      OperationUpload,
+			path:    "products/../private/shoe.jpg",
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := principal.Allows(tt.op, tt.path); got != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, got)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret-key"))
+	authenticator, err := NewAPIKeyAuthenticator([]APIKey{
+		{Name: "ci", KeySHA256: hex.EncodeToString(sum[:]), Operations: []Operation{OperationUpload}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	principal, err := authenticator.Authenticate("secret-key")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if principal.Subject != "ci" {
+		t.Errorf("Expected subject ci, got %s", principal.Subject)
+	}
+
+	if _, err := authenticator.Authenticate("wrong-key"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if _, err := NewAPIKeyAuthenticator([]APIKey{{Name: "bad", KeySHA256: "not-a-hash"}}); err == nil {
+		t.Errorf("Expected error for invalid hash but got none")
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("jwt-secret")
+	authenticator, err := NewJWTAuthenticator(JWTOptions{HS256Secret: secret, Issuer: "auth.example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sign := func(claims jwtClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return token
+	}
+	validClaims := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "cms",
+			Issuer:    "auth.example.com",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Prefixes:   []string{"products/"},
+		Operations: []Operation{OperationDelete},
+	}
+	expiredClaims := validClaims
+	expiredClaims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongIssuerClaims := validClaims
+	wrongIssuerClaims.Issuer = "evil.example.com"
+
+	tests := []struct {
+		name     string
+		token    string
+		hasError bool
+	}{
+		{
+			name:     "valid token",
+			token:    sign(validClaims, secret),
+			hasError: false,
+		},
+		{
+			name:     "wrong secret",
+			token:    sign(validClaims, []byte("other-secret")),
+			hasError: true,
+		},
+		{
+			name:     "expired token",
+			token:    sign(expiredClaims, secret),
+			hasError: true,
+		},
+		{
+			name:     "wrong issuer",
+			token:    sign(wrongIssuerClaims, secret),
+			hasError: true,
+		},
+		{
+			name:     "not a JWT",
+			token:    "plain-api-key",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(tt.token)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if !principal.Allows(OperationDelete, "products/shoe.jpg") {
+				t.Errorf("Expected principal to be allowed to delete products/shoe.jpg")
+			}
+		})
+	}
+}
+
+func TestUsedUploadTokens(t *testing.T) {
+	token, err := NewUploadToken("uploads/a.jpg", "image/jpeg", 1024, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	used, err := NewUsedUploadTokens(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := used.Use(token); err != nil {
+		t.Errorf("Expected first use to succeed, got %v", err)
+	}
+	if err := used.Use(token); !errors.Is(err, ErrUsedUploadToken) {
+		t.Errorf("Expected ErrUsedUploadToken for second use, got %v", err)
+	}
+
+	// released after a failed upload, token can be retried
+	used.Release(token)
+	if err := used.Use(token); err != nil {
+		t.Errorf("Expected use after release to succeed, got %v", err)
+	}
+
+	// used tokens are remembered after restarts and by other instances
+	restarted, err := NewUsedUploadTokens(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := restarted.Use(token); !errors.Is(err, ErrUsedUploadToken) {
+		t.Errorf("Expected ErrUsedUploadToken after restart, got %v", err)
+	}
+
+	// expired tokens are forgotten
+	expired, err := NewUploadToken("uploads/b.jpg", "image/jpeg", 1024, -time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := restarted.Use(expired); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	restarted.pruned = time.Time{}
+	restarted.removeExpired()
+	if _, err := os.Stat(filepath.Join(dir, expired.Nonce)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected expired token to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, token.Nonce)); err != nil {
+		t.Errorf("Expected token to be kept until it expires, got %v", err)
+	}
+
+	if err := used.Use(&UploadToken{Nonce: "../escape"}); !errors.Is(err, ErrInvalidUploadToken) {
+		t.Errorf("Expected ErrInvalidUploadToken for invalid nonce, got %v", err)
+	}
+}
+
+func TestVerifyUploadToken(t *testing.T) {
+	secret := []byte("secret")
+	sign := func(token *UploadToken, secret []byte) string {
+		t.Helper()
+		signed, err := token.Sign(secret)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return signed
+	}
+
+	token, err := NewUploadToken("uploads/a.jpg", "image/jpeg", 1024, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expired, err := NewUploadToken("uploads/a.jpg", "image/jpeg", 1024, -time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// signed with empty key, as anyone can when secret is not configured
+	encoded, _, _ := strings.Cut(sign(token, secret), ".")
+	forged := encoded + "." + base64.RawURLEncoding.EncodeToString(signUploadToken(nil, encoded))
+
+	tests := []struct {
+		name     string
+		secret   []byte
+		signed   string
+		expected error
+	}{
+		{name: "valid", secret: secret, signed: sign(token, secret)},
+		{name: "other secret", secret: secret, signed: sign(token, []byte("other")), expected: ErrInvalidUploadToken},
+		{name: "tampered", secret: secret, signed: "x" + sign(token, secret), expected: ErrInvalidUploadToken},
+		{name: "expired", secret: secret, signed: sign(expired, secret), expected: ErrExpiredUploadToken},
+		{name: "empty secret", secret: nil, signed: forged, expected: ErrInvalidUploadToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verified, err := VerifyUploadToken(tt.secret, tt.signed)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, err)
+			}
+			if tt.expected == nil && *verified != *token {
+				t.Errorf("Expected %+v, got %+v", token, verified)
+			}
+		})
+	}
+
+	if _, err := token.Sign(nil); err == nil {
+		t.Errorf("Expected error signing with empty secret")
+	}
+}
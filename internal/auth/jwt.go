@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTOptions configures verification of JWTs, at least one of HS256Secret or
+// RS256PublicKey must be provided.
+type JWTOptions struct {
+	HS256Secret    []byte
+	RS256PublicKey []byte // PEM encoded public key
+	Issuer         string // verified when not empty
+	Audience       string // verified when not empty
+}
+
+// jwtClaims are the claims read from a JWT, permissions are carried by
+// `prefixes` and `operations` claims e.g.
+//
+//	{"sub": "cms", "exp": 1767225600, "prefixes": ["products/"], "operations": ["upload", "update"]}
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Prefixes   []string    `json:"prefixes"`
+	Operations []Operation `json:"operations"`
+}
+
+// JWTAuthenticator authenticates callers using JWTs signed with HS256 or RS256.
+type JWTAuthenticator struct {
+	hs256Secret    []byte
+	rs256PublicKey *rsa.PublicKey
+	parser         *jwt.Parser
+}
+
+func NewJWTAuthenticator(opts JWTOptions) (*JWTAuthenticator, error) {
+	methods := make([]string, 0, 2)
+	authenticator := &JWTAuthenticator{}
+
+	if len(opts.HS256Secret) > 0 {
+		authenticator.hs256Secret = opts.HS256Secret
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(opts.RS256PublicKey) > 0 {
+		key, err := jwt.ParseRSAPublicKeyFromPEM(opts.RS256PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RS256 public key: %w", err)
+		}
+		authenticator.rs256PublicKey = key
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("either HS256 secret or RS256 public key is required")
+	}
+
+	parserOpts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	authenticator.parser = jwt.NewParser(parserOpts...)
+
+	return authenticator, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(token string) (*Principal, error) {
+	// not a JWT, let other authenticators try
+	if strings.Count(token, ".") != 2 {
+		return nil, ErrInvalidCredentials
+	}
+
+	claims := &jwtClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, a.key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+
+	return &Principal{
+		Subject:    claims.Subject,
+		Prefixes:   claims.Prefixes,
+		Operations: claims.Operations,
+	}, nil
+}
+
+// key returns the key to verify `token` with, based on its signing method
+func (a *JWTAuthenticator) key(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.hs256Secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return a.rs256PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+	}
+}
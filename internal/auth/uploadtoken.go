@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidUploadToken = errors.New("invalid upload token")
+	ErrExpiredUploadToken = errors.New("upload token expired")
+	ErrUsedUploadToken    = errors.New("upload token already used")
+)
+
+// UploadToken grants a one-time upload of a single image, restricted to a
+// file name, content type and maximum size. It is an alternative to presigned
+// URLs for sources which do not support them, e.g. local disk.
+type UploadToken struct {
+	Filename    string    `json:"f"`
+	ContentType string    `json:"ct"`
+	MaxSize     int64     `json:"s"`
+	ExpiresAt   time.Time `json:"exp"`
+	Nonce       string    `json:"n"`
+}
+
+// NewUploadToken returns a token with a random nonce, expiring after `expires`.
+func NewUploadToken(filename, contentType string, maxSize int64, expires time.Duration) (*UploadToken, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &UploadToken{
+		Filename:    filename,
+		ContentType: contentType,
+		MaxSize:     maxSize,
+		ExpiresAt:   time.Now().Add(expires).UTC().Truncate(time.Second),
+		Nonce:       hex.EncodeToString(nonce),
+	}, nil
+}
+
+// Sign returns URL safe string form of the token signed with HMAC-SHA256 using `secret`.
+// Empty `secret` is rejected, anyone could sign tokens with it.
+func (t *UploadToken) Sign(secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("upload token secret is required")
+	}
+
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode upload token: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signUploadToken(secret, encoded)), nil
+}
+
+// VerifyUploadToken verifies signature and expiry of a signed token and returns it.
+// Tokens are not checked for reuse, see UsedUploadTokens. All tokens are invalid
+// when `secret` is empty.
+func VerifyUploadToken(secret []byte, signed string) (*UploadToken, error) {
+	if len(secret) == 0 {
+		return nil, ErrInvalidUploadToken
+	}
+
+	encoded, signature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidUploadToken
+	}
+
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sum, signUploadToken(secret, encoded)) {
+		return nil, ErrInvalidUploadToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidUploadToken
+	}
+	token := &UploadToken{}
+	if err := json.Unmarshal(payload, token); err != nil {
+		return nil, ErrInvalidUploadToken
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrExpiredUploadToken
+	}
+
+	return token, nil
+}
+
+func signUploadToken(secret []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// UsedUploadTokens tracks nonces of consumed upload tokens until they expire.
+// Nonces are kept as files in a directory, so that tokens can not be reused
+// after restarts; server instances must share the directory e.g. on a shared volume.
+type UsedUploadTokens struct {
+	dir string
+
+	mu     sync.Mutex
+	pruned time.Time // last time expired nonces were removed
+}
+
+// NewUsedUploadTokens returns UsedUploadTokens keeping nonces in `dir`, it is
+// created if not present.
+func NewUsedUploadTokens(dir string) (*UsedUploadTokens, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create used upload tokens directory: %w", err)
+	}
+	return &UsedUploadTokens{dir: dir}, nil
+}
+
+// Use marks the token as used, ErrUsedUploadToken is returned if it was used before.
+func (u *UsedUploadTokens) Use(token *UploadToken) error {
+	if _, err := hex.DecodeString(token.Nonce); err != nil || token.Nonce == "" {
+		return ErrInvalidUploadToken
+	}
+	u.removeExpired()
+
+	// exclusive create is atomic, also across instances sharing the directory
+	file, err := os.OpenFile(filepath.Join(u.dir, token.Nonce), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return ErrUsedUploadToken
+	} else if err != nil {
+		return fmt.Errorf("failed to mark upload token used: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(token.ExpiresAt.Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to mark upload token used: %w", err)
+	}
+	return nil
+}
+
+// Release marks the token as not used, e.g. when storing the upload failed, so
+// that the upload can be retried with the same token.
+func (u *UsedUploadTokens) Release(token *UploadToken) {
+	if _, err := hex.DecodeString(token.Nonce); err != nil || token.Nonce == "" {
+		return
+	}
+	os.Remove(filepath.Join(u.dir, token.Nonce))
+}
+
+// removeExpired forgets expired tokens at most once a minute, they are rejected
+// by VerifyUploadToken anyway.
+func (u *UsedUploadTokens) removeExpired() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(u.pruned) < time.Minute {
+		return
+	}
+	u.pruned = now
+
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(u.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// nonces being written are empty, they are not expired
+		if expiresAt, err := time.Parse(time.RFC3339, string(data)); err == nil && now.After(expiresAt) {
+			os.Remove(path)
+		}
+	}
+}
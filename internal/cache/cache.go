@@ -0,0 +1,241 @@
+// package cache stores transformed images on local disk so that repeated
+// requests for the same image and transformations are served without transforming again.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrCacheMiss = errors.New("transformed image not found in cache")
+
+// versionStripes bounds versions of removed images, see Cache.versions
+const versionStripes = 1024
+
+// Cache keeps variants of a source image in a directory named by hash of the
+// image path, so that all variants of an image can be removed together. Least
+// recently used variants are evicted when size of the cache exceeds maxSize.
+type Cache struct {
+	dir     string
+	maxSize int64 // no limit when 0
+
+	mu       sync.Mutex
+	size     int64
+	lru      *list.List                          // of *entry, most recently used first
+	variants map[string]map[string]*list.Element // by image dir & variant file names
+	// incremented on Remove, so that variants of removed images are not cached.
+	// Striped by image path to keep it bounded, removal of an image then skips
+	// caching variants of other images of the stripe being transformed meanwhile.
+	versions [versionStripes]uint64
+}
+
+type entry struct {
+	image   string // image dir name
+	variant string // variant file name
+	size    int64
+}
+
+// New returns a Cache storing transformed images in `dir`, up to `maxSize` bytes
+// or without limit when `maxSize` is 0. Variants cached by previous runs are kept,
+// oldest of them are evicted first.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &Cache{dir: dir, maxSize: maxSize, lru: list.New(), variants: make(map[string]map[string]*list.Element)}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.evict()
+	return c, nil
+}
+
+// Version returns the current version of `imagePath`, it must be read before
+// fetching the source image and passed to Set.
+func (c *Cache) Version(imagePath string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.versions[stripe(imagePath)]
+}
+
+// Get returns the transformed image for `imagePath` and `options`, ErrCacheMiss if not present.
+// `options` must be canonical i.e. same transformations always use the same string.
+func (c *Cache) Get(imagePath, options string) ([]byte, error) {
+	data, err := os.ReadFile(c.variantPath(imagePath, options))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrCacheMiss
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cached image: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.variants[hash(imagePath)][hash(options)]; ok {
+		c.lru.MoveToFront(element)
+	}
+	return data, nil
+}
+
+// Set stores the transformed image for `imagePath` and `options`. It is a no-op
+// if the image was removed after `version` was read, as the variant may be stale,
+// or when the image is larger than the cache.
+func (c *Cache) Set(imagePath, options string, data []byte, version uint64) error {
+	if c.maxSize > 0 && int64(len(data)) > c.maxSize {
+		return nil
+	}
+
+	dir := c.imageDir(imagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to cache image: %w", err)
+	}
+
+	// write & rename so that partially written variants are never served
+	tmp, err := os.CreateTemp(dir, ".variant-*")
+	if err != nil {
+		return fmt.Errorf("failed to cache image: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to cache image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to cache image: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.versions[stripe(imagePath)] != version {
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), c.variantPath(imagePath, options)); err != nil {
+		return fmt.Errorf("failed to cache image: %w", err)
+	}
+	c.add(hash(imagePath), hash(options), int64(len(data)))
+	c.evict()
+	return nil
+}
+
+// Remove deletes all cached variants of `imagePath`, e.g. after the source image is updated.
+func (c *Cache) Remove(imagePath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.versions[stripe(imagePath)]++
+	for _, element := range c.variants[hash(imagePath)] {
+		c.size -= element.Value.(*entry).size
+		c.lru.Remove(element)
+	}
+	delete(c.variants, hash(imagePath))
+	if err := os.RemoveAll(c.imageDir(imagePath)); err != nil {
+		return fmt.Errorf("failed to remove cached images: %w", err)
+	}
+	return nil
+}
+
+// load indexes variants present in the cache directory, in order of their
+// modification time. Partially written variants are deleted.
+func (c *Cache) load() error {
+	type file struct {
+		entry
+		modTime time.Time
+	}
+	var files []file
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".variant-") {
+			return os.Remove(path)
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return err
+		}
+		image, variant, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok || strings.Contains(variant, "/") {
+			return nil // not a variant
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{entry{image, variant, info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load cache directory: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		c.add(f.image, f.variant, f.size)
+	}
+	return nil
+}
+
+// add marks the variant as most recently used, c.mu must be held
+func (c *Cache) add(image, variant string, size int64) {
+	if element, ok := c.variants[image][variant]; ok {
+		c.size -= element.Value.(*entry).size
+		c.lru.Remove(element)
+	}
+	if c.variants[image] == nil {
+		c.variants[image] = make(map[string]*list.Element)
+	}
+	c.variants[image][variant] = c.lru.PushFront(&entry{image, variant, size})
+	c.size += size
+}
+
+// evict deletes least recently used variants until the cache fits in maxSize,
+// c.mu must be held. Variants failing to be deleted are forgotten, so that
+// eviction does not get stuck.
+func (c *Cache) evict() {
+	for c.maxSize > 0 && c.size > c.maxSize {
+		e := c.lru.Remove(c.lru.Back()).(*entry)
+		c.size -= e.size
+		delete(c.variants[e.image], e.variant)
+		if len(c.variants[e.image]) == 0 {
+			delete(c.variants, e.image)
+		}
+
+		os.Remove(filepath.Join(c.dir, e.image, e.variant))
+		// removed only when empty, i.e. no variant is being written
+		os.Remove(filepath.Join(c.dir, e.image))
+	}
+}
+
+func (c *Cache) imageDir(imagePath string) string {
+	return filepath.Join(c.dir, hash(imagePath))
+}
+
+func (c *Cache) variantPath(imagePath, options string) string {
+	return filepath.Join(c.imageDir(imagePath), hash(options))
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func stripe(imagePath string) int {
+	h := fnv.New32a()
+	h.Write([]byte(imagePath))
+	return int(h.Sum32() % versionStripes)
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := New(t.TempDir(), 30)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data := make([]byte, 10)
+
+	for _, image := range []string{"a.png", "b.png", "c.png"} {
+		if err := c.Set(image, "width=10", data, c.Version(image)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// a.png is used, b.png is least recently used now
+	if _, err := c.Get("a.png", "width=10"); err != nil {
+		t.Fatalf("Expected a.png to be cached, got %v", err)
+	}
+	if err := c.Set("d.png", "width=10", data, c.Version("d.png")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// larger than the cache
+	if err := c.Set("e.png", "width=10", make([]byte, 31), c.Version("e.png")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		image  string
+		cached bool
+	}{
+		{image: "a.png", cached: true},
+		{image: "b.png", cached: false},
+		{image: "c.png", cached: true},
+		{image: "d.png", cached: true},
+		{image: "e.png", cached: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			_, err := c.Get(tt.image, "width=10")
+			if tt.cached && err != nil {
+				t.Errorf("Expected %s to be cached, got %v", tt.image, err)
+			} else if !tt.cached && !errors.Is(err, ErrCacheMiss) {
+				t.Errorf("Expected %s to be evicted, got %v", tt.image, err)
+			}
+		})
+	}
+	if c.size != 30 {
+		t.Errorf("Expected size 30, got %d", c.size)
+	}
+}
+
+func TestCacheRemove(t *testing.T) {
+	c, err := New(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	version := c.Version("a.png")
+	for _, options := range []string{"width=10", "width=20"} {
+		if err := c.Set("a.png", options, make([]byte, 10), version); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := c.Remove("a.png"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.size != 0 || c.lru.Len() != 0 || len(c.variants) != 0 {
+		t.Errorf("Expected removed variants to be forgotten, got size %d and %d variants", c.size, c.lru.Len())
+	}
+
+	// transformed before removal, stale
+	if err := c.Set("a.png", "width=10", make([]byte, 10), version); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Get("a.png", "width=10"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected stale variant not to be cached, got %v", err)
+	}
+}
+
+func TestCacheLoadsVariantsOfPreviousRuns(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, image := range []string{"a.png", "b.png"} {
+		if err := c.Set(image, "width=10", make([]byte, 10), c.Version(image)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// a.png is older
+		modTime := time.Now().Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(c.variantPath(image, "width=10"), modTime, modTime); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	partial := filepath.Join(c.imageDir("a.png"), ".variant-1")
+	if err := os.WriteFile(partial, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	c, err = New(dir, 15)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Get("a.png", "width=10"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected oldest variant to be evicted, got %v", err)
+	}
+	if _, err := c.Get("b.png", "width=10"); err != nil {
+		t.Errorf("Expected b.png to be cached, got %v", err)
+	}
+	if _, err := os.Stat(partial); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected partially written variant to be deleted, got %v", err)
+	}
+}
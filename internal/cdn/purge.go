@@ -0,0 +1,145 @@
+// package cdn purges transformed images cached at CDNs when the source image
+// changes. Responses of transform route are tagged with SurrogateKey of the
+// source image, purgers invalidate all variants of an image using the key.
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Purger invalidates all cached variants of a source image.
+type Purger interface {
+	Purge(ctx context.Context, imagePath string) error
+}
+
+// SurrogateKey returns the tag of all variants of `imagePath`, sent as
+// `Surrogate-Key` & `Cache-Tag` headers. Keys are hashed so that any path
+// results in a valid header value.
+func SurrogateKey(imagePath string) string {
+	sum := sha256.Sum256([]byte(imagePath))
+	return "kriti-" + hex.EncodeToString(sum[:12])
+}
+
+// Purgers purges using every purger, errors of all purgers are returned.
+type Purgers []Purger
+
+func (p Purgers) Purge(ctx context.Context, imagePath string) error {
+	var errs []error
+	for _, purger := range p {
+		if err := purger.Purge(ctx, imagePath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HTTPPurger sends a request to URL, `{key}` and `{path}` placeholders in URL
+// are replaced by surrogate key and image path, e.g. `https://cdn.example.com/purge/{key}`.
+type HTTPPurger struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func (p *HTTPPurger) Purge(ctx context.Context, imagePath string) error {
+	target := strings.NewReplacer(
+		"{key}", url.PathEscape(SurrogateKey(imagePath)),
+		"{path}", url.PathEscape(imagePath),
+	).Replace(p.URL)
+
+	return send(ctx, p.Client, p.Method, target, p.Headers, nil)
+}
+
+// FastlyPurger purges by surrogate key using Fastly API.
+type FastlyPurger struct {
+	ServiceID string
+	APIToken  string
+	SoftPurge bool // marks content stale instead of removing it
+	Client    *http.Client
+}
+
+func (p *FastlyPurger) Purge(ctx context.Context, imagePath string) error {
+	target := fmt.Sprintf("https://api.fastly.com/service/%s/purge/%s", url.PathEscape(p.ServiceID), SurrogateKey(imagePath))
+	headers := map[string]string{"Fastly-Key": p.APIToken}
+	if p.SoftPurge {
+		headers["Fastly-Soft-Purge"] = "1"
+	}
+
+	return send(ctx, p.Client, http.MethodPost, target, headers, nil)
+}
+
+// CloudflarePurger purges by cache tag using Cloudflare API.
+type CloudflarePurger struct {
+	ZoneID   string
+	APIToken string
+	Client   *http.Client
+}
+
+func (p *CloudflarePurger) Purge(ctx context.Context, imagePath string) error {
+	target := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/purge_cache", url.PathEscape(p.ZoneID))
+	headers := map[string]string{
+		"Authorization": "Bearer " + p.APIToken,
+		"Content-Type":  "application/json",
+	}
+	body, err := json.Marshal(map[string][]string{"tags": {SurrogateKey(imagePath)}})
+	if err != nil {
+		return fmt.Errorf("failed to encode purge request: %w", err)
+	}
+
+	return send(ctx, p.Client, http.MethodPost, target, headers, body)
+}
+
+// VarnishPurger sends a BAN request with `X-Surrogate-Key` header, VCL must
+// ban objects having the key in their `Surrogate-Key` header, e.g.
+//
+//	if (req.method == "BAN") {
+//	    ban("obj.http.Surrogate-Key ~ " + req.http.X-Surrogate-Key);
+//	    return (synth(200, "Banned"));
+//	}
+type VarnishPurger struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *VarnishPurger) Purge(ctx context.Context, imagePath string) error {
+	headers := map[string]string{"X-Surrogate-Key": SurrogateKey(imagePath)}
+	return send(ctx, p.Client, "BAN", p.URL, headers, nil)
+}
+
+func send(ctx context.Context, client *http.Client, method, target string, headers map[string]string, body []byte) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create purge request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to purge %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to purge %s: status %d; %s", req.URL.Host, resp.StatusCode, string(message))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cdn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPurgers(t *testing.T) {
+	key := SurrogateKey("products/shoe.jpg")
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Surrogate-Key"))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		purger   Purger
+		expected string
+		hasError bool
+	}{
+		{
+			name:     "http purge by key",
+			purger:   &HTTPPurger{Method: "PURGE", URL: server.URL + "/purge/{key}"},
+			expected: "PURGE /purge/" + key + " ",
+		},
+		{
+			name:     "varnish ban",
+			purger:   &VarnishPurger{URL: server.URL + "/"},
+			expected: "BAN / " + key,
+		},
+		{
+			name:     "failed purge",
+			purger:   &HTTPPurger{Method: "POST", URL: server.URL + "/fail"},
+			expected: "POST /fail ",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			err := Purgers{tt.purger}.Purge(context.Background(), "products/shoe.jpg")
+
+			if tt.hasError && err == nil {
+				t.Errorf("Expected error but got none")
+			} else if !tt.hasError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if len(requests) != 1 || requests[0] != tt.expected {
+				t.Errorf("Expected request %q, got %q", tt.expected, requests)
+			}
+		})
+	}
+}
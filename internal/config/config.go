@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,6 +12,9 @@ import (
 type Config struct {
 	Server       ServerConfig       `mapstructure:"server"`
 	Images       ImagesConfig       `mapstructure:"images"`
+	API          APIConfig          `mapstructure:"api"`
+	Webhooks     WebhooksConfig     `mapstructure:"webhooks"`
+	CDN          CDNConfig          `mapstructure:"cdn"`
 	Experimental ExperimentalConfig `mapstructure:"experimental"`
 }
 
@@ -33,9 +38,28 @@ type CrossOriginPolicy struct {
 
 // ImagesConfig holds image-specific configuration
 type ImagesConfig struct {
-	Source string            `mapstructure:"source"`
-	AwsS3  ImagesConfigAWSS3 `mapstructure:"awss3"`
-	Local  ImagesConfigLocal `mapstructure:"local"`
+	Source    string                `mapstructure:"source"`
+	AwsS3     ImagesConfigAWSS3     `mapstructure:"awss3"`
+	GCS       ImagesConfigGCS       `mapstructure:"gcs"`
+	AzureBlob ImagesConfigAzureBlob `mapstructure:"azureblob"`
+	WebFolder ImagesConfigWebFolder `mapstructure:"webfolder"`
+	Local     ImagesConfigLocal     `mapstructure:"local"`
+	Cache     ImagesConfigCache     `mapstructure:"cache"`
+
+	// Watermarks are overlays drawn over every transformed image of a source, keyed by source name
+	Watermarks map[string]ImagesConfigWatermark `mapstructure:"watermarks"`
+
+	// Fonts used by `text` transformation
+	Fonts ImagesConfigFonts `mapstructure:"fonts"`
+
+	// Templates rendered by template route, e.g. social cards
+	Templates ImagesConfigTemplates `mapstructure:"templates"`
+
+	// CachePolicy controls caching headers of transformed images and error responses
+	CachePolicy ImagesConfigCachePolicy `mapstructure:"cache_policy"`
+
+	// Presets are named transformations used as `preset=<name>`, e.g. thumb: "width=200,height=200,format=webp"
+	Presets map[string]string `mapstructure:"presets"`
 
 	MaxImageDimension   int   `mapstructure:"max_image_dimension"`
 	MaxImageSizeInBytes int64 `mapstructure:"max_file_size_in_bytes"`
@@ -45,10 +69,171 @@ type ImagesConfigAWSS3 struct {
 	Bucket string `mapstructure:"bucket"`
 }
 
+type ImagesConfigGCS struct {
+	Bucket          string `mapstructure:"bucket"`
+	CredentialsFile string `mapstructure:"credentials_file"` // service account JSON, uses application default credentials when empty
+	Endpoint        string `mapstructure:"endpoint"`         // custom endpoint e.g. fake-gcs-server for local testing
+}
+
+type ImagesConfigAzureBlob struct {
+	Container        string `mapstructure:"container"`
+	ConnectionString string `mapstructure:"connection_string"` // takes precedence over endpoint and SAS token
+	Endpoint         string `mapstructure:"endpoint"`          // service URL e.g. https://<account>.blob.core.windows.net/ or Azurite
+	SASToken         string `mapstructure:"sas_token"`
+}
+
+type ImagesConfigWebFolder struct {
+	BaseURL string            `mapstructure:"base_url"` // e.g. https://assets.example.com/media/
+	Headers map[string]string `mapstructure:"headers"`  // sent with every request to origin, e.g. Authorization
+}
+
+// ImagesConfigCache holds configuration for cache of transformed images
+type ImagesConfigCache struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Dir            string `mapstructure:"dir"`
+	MaxSizeInBytes int64  `mapstructure:"max_size_in_bytes"` // least recently used images are evicted beyond it, 0 for no limit
+}
+
+// ImagesConfigCachePolicy holds caching headers of transform route responses.
+// Overrides are applied over Default in order: source, path prefix, preset; so
+// that the most specific one wins. Empty fields of overrides are inherited.
+type ImagesConfigCachePolicy struct {
+	Default  CachePolicy            `mapstructure:"default"`
+	NotFound CachePolicy            `mapstructure:"not_found"` // negative caching of missing images
+	Error    CachePolicy            `mapstructure:"error"`     // other 4xx and 5xx responses
+	Sources  map[string]CachePolicy `mapstructure:"sources"`   // keyed by source name, e.g. http
+	Paths    []CachePolicyPath      `mapstructure:"paths"`     // longest matching prefix is used
+	Presets  map[string]CachePolicy `mapstructure:"presets"`   // keyed by preset name
+}
+
+type CachePolicy struct {
+	CacheControl string `mapstructure:"cache_control"` // e.g. "public, max-age=300, stale-while-revalidate=60"
+	Robots       *bool  `mapstructure:"robots"`        // sends `X-Robots-Tag: noindex, nofollow` when true
+	AllowOrigin  string `mapstructure:"allow_origin"`  // Access-Control-Allow-Origin
+}
+
+type CachePolicyPath struct {
+	Prefix      string `mapstructure:"prefix"`
+	CachePolicy `mapstructure:",squash"`
+}
+
+// ImagesConfigWatermark holds a mandatory overlay of a source, see `overlay` transformation
+type ImagesConfigWatermark struct {
+	Path    string  `mapstructure:"path"`    // image path in any configured source
+	Gravity string  `mapstructure:"gravity"` // e.g. southeast or se
+	X       int     `mapstructure:"x"`
+	Y       int     `mapstructure:"y"`
+	Scale   float64 `mapstructure:"scale"`   // fraction of image dimensions, 0 keeps original size
+	Opacity int     `mapstructure:"opacity"` // 1 to 100, 0 is 100
+	Tile    bool    `mapstructure:"tile"`
+}
+
+// ImagesConfigFonts holds fonts of `text` transformation, Go fonts (goregular,
+// gobold, goitalic, gomono) are bundled.
+type ImagesConfigFonts struct {
+	Dir     string `mapstructure:"dir"`     // TTF and OTF files, family name is file name without extension
+	Default string `mapstructure:"default"` // family used when text_font is not set
+}
+
+// ImagesConfigTemplates holds templates of template route
+type ImagesConfigTemplates struct {
+	Dir string `mapstructure:"dir"` // YAML files, template name is file name without extension
+}
+
 type ImagesConfigLocal struct {
 	BasePath string `mapstructure:"base_path"`
 }
 
+// APIConfig holds configuration for APIs under /api, e.g. upload APIs
+type APIConfig struct {
+	Upload    APIConfigUpload    `mapstructure:"upload"`
+	Auth      APIConfigAuth      `mapstructure:"auth"`
+	Presign   APIConfigPresign   `mapstructure:"presign"`
+	Resumable APIConfigResumable `mapstructure:"resumable"`
+}
+
+type APIConfigUpload struct {
+	// Normalize decodes and re-encodes uploaded images instead of storing the original bytes
+	Normalize bool `mapstructure:"normalize"`
+	// ContentAddressed stores uploads with name derived from SHA-256 of the content,
+	// can be enabled per upload using `content_addressed` form field too
+	ContentAddressed bool `mapstructure:"content_addressed"`
+	// Derivatives are preset names or transformation options generated in background
+	// after every upload, requires images.cache
+	Derivatives       []string `mapstructure:"derivatives"`
+	DerivativeWorkers int      `mapstructure:"derivative_workers"`
+}
+
+// APIConfigPresign holds configuration for direct-to-storage uploads
+type APIConfigPresign struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	Secret             string        `mapstructure:"secret"` // signs one-time upload tokens for sources without presigned URLs e.g. local
+	Prefix             string        `mapstructure:"prefix"` // uploads are restricted to file names starting with prefix
+	MaxFileSizeInBytes int64         `mapstructure:"max_file_size_in_bytes"`
+	Expiration         time.Duration `mapstructure:"expiration"`
+	UsedTokensDir      string        `mapstructure:"used_tokens_dir"` // nonces of used one-time upload tokens, must be shared by all instances
+}
+
+// APIConfigResumable holds configuration for resumable uploads (tus protocol)
+type APIConfigResumable struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	StagingDir         string        `mapstructure:"staging_dir"` // local directory to keep chunks until upload completes
+	MaxFileSizeInBytes int64         `mapstructure:"max_file_size_in_bytes"`
+	Expiration         time.Duration `mapstructure:"expiration"` // incomplete uploads are removed after expiration
+}
+
+// APIConfigAuth holds authentication configuration for APIs, callers must
+// provide either a static API key or a JWT
+type APIConfigAuth struct {
+	Enabled bool               `mapstructure:"enabled"`
+	APIKeys []APIConfigAuthKey `mapstructure:"api_keys"`
+	JWT     APIConfigAuthJWT   `mapstructure:"jwt"`
+}
+
+type APIConfigAuthKey struct {
+	Name       string   `mapstructure:"name"`
+	KeySHA256  string   `mapstructure:"key_sha256"` // hex encoded SHA-256 hash of the key, never the key itself
+	Prefixes   []string `mapstructure:"prefixes"`   // allowed image path prefixes, empty allows all paths
+	Operations []string `mapstructure:"operations"` // allowed operations: read, upload, update, delete
+}
+
+type APIConfigAuthJWT struct {
+	HS256Secret        string `mapstructure:"hs256_secret"`
+	RS256PublicKeyFile string `mapstructure:"rs256_public_key_file"` // PEM encoded
+	Issuer             string `mapstructure:"issuer"`
+	Audience           string `mapstructure:"audience"`
+}
+
+// WebhooksConfig holds configuration for image lifecycle event notifications
+type WebhooksConfig struct {
+	Endpoints   []WebhooksConfigEndpoint `mapstructure:"endpoints"`
+	OutboxDir   string                   `mapstructure:"outbox_dir"` // events are stored here until delivered
+	MaxAttempts int                      `mapstructure:"max_attempts"`
+	Timeout     time.Duration            `mapstructure:"timeout"`
+}
+
+type WebhooksConfigEndpoint struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"` // signs request body using HMAC-SHA256
+	Events []string `mapstructure:"events"` // e.g. image.uploaded, image.updated, image.deleted, variant.generated; empty sends all
+}
+
+// CDNConfig holds configuration of CDNs caching transformed images
+type CDNConfig struct {
+	Purgers []CDNConfigPurger `mapstructure:"purgers"` // invoked when an image is updated or deleted
+}
+
+type CDNConfigPurger struct {
+	Type      string            `mapstructure:"type"`    // http, fastly, cloudflare or varnish
+	URL       string            `mapstructure:"url"`     // http & varnish, `{key}` and `{path}` are replaced for http
+	Method    string            `mapstructure:"method"`  // http, defaults to PURGE
+	Headers   map[string]string `mapstructure:"headers"` // http
+	ServiceID string            `mapstructure:"service_id"`
+	ZoneID    string            `mapstructure:"zone_id"`
+	APIToken  string            `mapstructure:"api_token"`
+	SoftPurge bool              `mapstructure:"soft_purge"`
+}
+
 // LimiterConfig holds rate limiter configuration
 type LimiterConfig struct {
 	Max        int           `mapstructure:"max"`
@@ -98,10 +283,57 @@ func setDefaults() {
 	// Images defaults
 	viper.SetDefault("images.source", "local")
 	viper.SetDefault("images.local.base_path", "")
+	viper.SetDefault("images.gcs.bucket", "")
+	viper.SetDefault("images.gcs.credentials_file", "")
+	viper.SetDefault("images.gcs.endpoint", "")
+	viper.SetDefault("images.azureblob.container", "")
+	viper.SetDefault("images.azureblob.connection_string", "")
+	viper.SetDefault("images.azureblob.endpoint", "")
+	viper.SetDefault("images.azureblob.sas_token", "")
+	viper.SetDefault("images.webfolder.base_url", "")
+	viper.SetDefault("images.cache.enabled", false)
+	viper.SetDefault("images.cache.dir", filepath.Join(os.TempDir(), "kriti-cache"))
+	viper.SetDefault("images.cache.max_size_in_bytes", 1024*1024*1024) // 1GB
+	viper.SetDefault("images.watermarks", map[string]any{})
+	viper.SetDefault("images.fonts.dir", "")
+	viper.SetDefault("images.fonts.default", "goregular")
+	viper.SetDefault("images.templates.dir", "")
+	viper.SetDefault("images.presets.lqip", "width=32,fit=contain,blur=1,quality=50,format=datauri")     // tiny blurred placeholder as data URI
+	viper.SetDefault("images.cache_policy.default.cache_control", "public, max-age=31536000, immutable") // 1 year
+	viper.SetDefault("images.cache_policy.default.robots", true)
+	viper.SetDefault("images.cache_policy.default.allow_origin", "*")
+	viper.SetDefault("images.cache_policy.not_found.cache_control", "public, max-age=60")
+	viper.SetDefault("images.cache_policy.error.cache_control", "no-store")
 
 	viper.SetDefault("images.max_dimension", 8192)                  // 8K
 	viper.SetDefault("images.max_file_size_in_bytes", 50*1024*1024) // 50MB
 
+	// API defaults
+	viper.SetDefault("api.upload.normalize", false)
+	viper.SetDefault("api.upload.content_addressed", false)
+	viper.SetDefault("api.upload.derivatives", []string{})
+	viper.SetDefault("api.upload.derivative_workers", 2)
+	viper.SetDefault("api.auth.enabled", false)
+	viper.SetDefault("api.presign.enabled", false)
+	viper.SetDefault("api.presign.secret", "")
+	viper.SetDefault("api.presign.prefix", "uploads/")
+	viper.SetDefault("api.presign.max_file_size_in_bytes", 50*1024*1024) // 50MB
+	viper.SetDefault("api.presign.expiration", "15m")
+	viper.SetDefault("api.presign.used_tokens_dir", filepath.Join(os.TempDir(), "kriti-upload-tokens"))
+	viper.SetDefault("api.resumable.enabled", false)
+	viper.SetDefault("api.resumable.staging_dir", filepath.Join(os.TempDir(), "kriti-uploads"))
+	viper.SetDefault("api.resumable.max_file_size_in_bytes", 50*1024*1024) // 50MB
+	viper.SetDefault("api.resumable.expiration", "24h")
+
+	// Webhooks defaults
+	viper.SetDefault("webhooks.endpoints", []map[string]any{})
+	viper.SetDefault("webhooks.outbox_dir", filepath.Join(os.TempDir(), "kriti-outbox"))
+	viper.SetDefault("webhooks.max_attempts", 10)
+	viper.SetDefault("webhooks.timeout", "10s")
+
+	// CDN defaults
+	viper.SetDefault("cdn.purgers", []map[string]any{})
+
 	// Rate limiter defaults
 	viper.SetDefault("server.limiter.max", 100)
 	viper.SetDefault("server.limiter.expiration", "1m")
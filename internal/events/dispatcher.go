@@ -0,0 +1,263 @@
+package events
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+const (
+	pollInterval = time.Second
+	maxBackoff   = time.Hour
+)
+
+// retryBackoff is doubled on every attempt to get delay before the next one
+var retryBackoff = time.Second
+
+// Webhook is an endpoint receiving events.
+type Webhook struct {
+	URL    string
+	Secret string // signs request body, see Sign
+	Events []Type // events sent to the webhook, empty sends all
+}
+
+// Options configures delivery of events.
+type Options struct {
+	OutboxDir   string        // pending deliveries are stored here, failed ones in `failed` sub directory
+	MaxAttempts int           // delivery is abandoned after these many attempts
+	Timeout     time.Duration // of each webhook request
+}
+
+// delivery is an event pending to be sent to a webhook, stored in outbox as JSON
+type delivery struct {
+	Event         *Event    `json:"event"`
+	URL           string    `json:"url"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// Dispatcher delivers events to webhooks in background. Each webhook is sent
+// its events in order, by a goroutine of its own so that a slow or failing
+// webhook does not delay others. A nil Dispatcher is valid and drops all
+// events, i.e. when no webhooks are configured.
+type Dispatcher struct {
+	webhooks []Webhook
+	options  Options
+	client   *http.Client
+	notify   []chan struct{} // per webhook
+}
+
+// NewDispatcher returns a Dispatcher and starts delivering events to `webhooks`,
+// including events left in the outbox by a previous run.
+func NewDispatcher(webhooks []Webhook, options Options) (*Dispatcher, error) {
+	if err := os.MkdirAll(filepath.Join(options.OutboxDir, "failed"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	// deliveries are stored per URL
+	for i, webhook := range webhooks {
+		if slices.ContainsFunc(webhooks[:i], func(w Webhook) bool { return w.URL == webhook.URL }) {
+			return nil, fmt.Errorf("duplicate webhook %s", webhook.URL)
+		}
+	}
+
+	d := &Dispatcher{
+		webhooks: webhooks,
+		options:  options,
+		client:   &http.Client{Timeout: options.Timeout},
+		notify:   make([]chan struct{}, len(webhooks)),
+	}
+	d.dropUnknown()
+	for i, webhook := range webhooks {
+		d.notify[i] = make(chan struct{}, 1)
+		go d.run(webhook, d.notify[i])
+	}
+	return d, nil
+}
+
+// Emit stores the event in outbox for each subscribed webhook, delivery happens in background.
+func (d *Dispatcher) Emit(t Type, data map[string]any) {
+	if d == nil {
+		return
+	}
+
+	event := NewEvent(t, data)
+	for i, webhook := range d.webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, t) {
+			continue
+		}
+
+		name := fmt.Sprintf("%d-%s-%s.json", event.CreatedAt.UnixNano(), event.ID, webhookKey(webhook.URL))
+		if err := d.save(name, &delivery{Event: event, URL: webhook.URL, NextAttemptAt: event.CreatedAt}); err != nil {
+			log.Errorw("failed to store event in outbox", "event", event.Type, "id", event.ID, "url", webhook.URL, "error", err.Error())
+			continue
+		}
+
+		select {
+		case d.notify[i] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *Dispatcher) run(webhook Webhook, notify chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverPending(webhook)
+
+		select {
+		case <-ticker.C:
+		case <-notify:
+		}
+	}
+}
+
+// deliverPending sends due deliveries of `webhook` from outbox in order of
+// creation. While a delivery waits to be retried later ones wait too, so that
+// e.g. deletion of an image is never delivered before its upload.
+func (d *Dispatcher) deliverPending(webhook Webhook) {
+	entries, err := os.ReadDir(d.options.OutboxDir)
+	if err != nil {
+		log.Errorw("failed to read outbox", "error", err.Error())
+		return
+	}
+
+	suffix := "-" + webhookKey(webhook.URL) + ".json"
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+
+		item, err := d.load(entry.Name())
+		if err != nil {
+			log.Errorw("failed to read event from outbox", "file", entry.Name(), "error", err.Error())
+			d.fail(entry.Name())
+			continue
+		}
+		if time.Now().Before(item.NextAttemptAt) {
+			return
+		}
+
+		if err := d.send(item, webhook.Secret); err != nil {
+			item.Attempts++
+			if item.Attempts >= d.options.MaxAttempts {
+				log.Errorw("failed to deliver event, giving up", "event", item.Event.Type, "id", item.Event.ID, "url", item.URL, "attempts", item.Attempts, "error", err.Error())
+				d.fail(entry.Name())
+				continue
+			}
+
+			item.NextAttemptAt = time.Now().Add(min(retryBackoff<<item.Attempts, maxBackoff))
+			log.Warnw("failed to deliver event, will retry", "event", item.Event.Type, "id", item.Event.ID, "url", item.URL, "attempts", item.Attempts, "next_attempt_at", item.NextAttemptAt, "error", err.Error())
+			if err := d.save(entry.Name(), item); err != nil {
+				log.Errorw("failed to update event in outbox", "id", item.Event.ID, "error", err.Error())
+			}
+			return
+		}
+
+		log.Infow("event delivered", "event", item.Event.Type, "id", item.Event.ID, "url", item.URL)
+		os.Remove(filepath.Join(d.options.OutboxDir, entry.Name()))
+	}
+}
+
+// dropUnknown removes deliveries left in outbox for webhooks which are not
+// configured anymore.
+func (d *Dispatcher) dropUnknown() {
+	entries, err := os.ReadDir(d.options.OutboxDir)
+	if err != nil {
+		log.Errorw("failed to read outbox", "error", err.Error())
+		return
+	}
+
+	keys := make([]string, 0, len(d.webhooks))
+	for _, webhook := range d.webhooks {
+		keys = append(keys, "-"+webhookKey(webhook.URL)+".json")
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if !slices.ContainsFunc(keys, func(key string) bool { return strings.HasSuffix(entry.Name(), key) }) {
+			log.Warnw("webhook is not configured anymore, dropping event", "file", entry.Name())
+			os.Remove(filepath.Join(d.options.OutboxDir, entry.Name()))
+		}
+	}
+}
+
+// webhookKey identifies deliveries of the webhook with `url` in outbox file names
+func webhookKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (d *Dispatcher) send(item *delivery, secret string) error {
+	body, err := json.Marshal(item.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, item.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kriti-Images-Webhook")
+	req.Header.Set("X-Kriti-Event", string(item.Event.Type))
+	req.Header.Set("X-Kriti-Delivery", item.Event.ID)
+	req.Header.Set("X-Kriti-Signature", Sign([]byte(secret), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) load(name string) (*delivery, error) {
+	data, err := os.ReadFile(filepath.Join(d.options.OutboxDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	item := &delivery{}
+	if err := json.Unmarshal(data, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (d *Dispatcher) save(name string, item *delivery) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	// write & rename so that deliveries are never read partially written
+	path := filepath.Join(d.options.OutboxDir, name)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// fail moves a delivery out of outbox for manual inspection
+func (d *Dispatcher) fail(name string) {
+	if err := os.Rename(filepath.Join(d.options.OutboxDir, name), filepath.Join(d.options.OutboxDir, "failed", name)); err != nil {
+		log.Errorw("failed to move event out of outbox", "file", name, "error", err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherDelivery(t *testing.T) {
+	secret := "webhook-secret"
+	received := make(chan *Event, 2)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get("X-Kriti-Signature"); got != Sign([]byte(secret), body) {
+			t.Errorf("Expected valid signature, got %s", got)
+		}
+
+		event := &Event{}
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewDispatcher(
+		[]Webhook{{URL: server.URL, Secret: secret, Events: []Type{ImageDeleted}}},
+		Options{OutboxDir: t.TempDir(), MaxAttempts: 3, Timeout: time.Second},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dispatcher.Emit(ImageUploaded, map[string]any{"filename": "shoe.jpg"})
+	dispatcher.Emit(ImageDeleted, map[string]any{"filename": "shoe.jpg"})
+
+	select {
+	case event := <-received:
+		if event.Type != ImageDeleted {
+			t.Errorf("Expected %s event, got %s", ImageDeleted, event.Type)
+		}
+		if event.Data["filename"] != "shoe.jpg" {
+			t.Errorf("Expected filename shoe.jpg, got %v", event.Data["filename"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected event to be delivered")
+	}
+
+	select {
+	case event := <-received:
+		t.Errorf("Expected only subscribed events, got %s", event.Type)
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func TestDispatcherOrderOnRetry(t *testing.T) {
+	retryBackoff = 10 * time.Millisecond
+	defer func() { retryBackoff = time.Second }()
+
+	var mu sync.Mutex
+	var received []Type
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, Type(r.Header.Get("X-Kriti-Event")))
+		if len(received) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dispatcher, err := NewDispatcher(
+		[]Webhook{{URL: server.URL}},
+		Options{OutboxDir: t.TempDir(), MaxAttempts: 3, Timeout: time.Second},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dispatcher.Emit(ImageUploaded, map[string]any{"filename": "shoe.jpg"})
+	dispatcher.Emit(ImageDeleted, map[string]any{"filename": "shoe.jpg"})
+
+	expected := []Type{ImageUploaded, ImageUploaded, ImageDeleted}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		mu.Lock()
+		done := len(received) >= len(expected)
+		mu.Unlock()
+		if done {
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(received, expected) {
+		t.Errorf("Expected %v, got %v", expected, received)
+	}
+}
+
+func TestDispatcherSlowWebhook(t *testing.T) {
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer slow.Close()
+	defer close(unblock)
+
+	received := make(chan *Event, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := &Event{}
+		json.NewDecoder(r.Body).Decode(event)
+		received <- event
+	}))
+	defer fast.Close()
+
+	dispatcher, err := NewDispatcher(
+		[]Webhook{{URL: slow.URL}, {URL: fast.URL}},
+		Options{OutboxDir: t.TempDir(), MaxAttempts: 3, Timeout: 10 * time.Second},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dispatcher.Emit(ImageUploaded, map[string]any{"filename": "shoe.jpg"})
+
+	select {
+	case event := <-received:
+		if event.Type != ImageUploaded {
+			t.Errorf("Expected %s event, got %s", ImageUploaded, event.Type)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected event to be delivered while other webhook is slow")
+	}
+}
+
+func TestNilDispatcher(t *testing.T) {
+	var dispatcher *Dispatcher
+	dispatcher.Emit(ImageUploaded, nil) // must not panic
+}
@@ -0,0 +1,52 @@
+// package events delivers image lifecycle events to webhooks. Events are written
+// to an on-disk outbox before delivery, so that they survive restarts, and are
+// retried with exponential backoff until delivered.
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Type is the type of an event, as sent in `type` field and `X-Kriti-Event` header
+type Type string
+
+const (
+	ImageUploaded    Type = "image.uploaded"
+	ImageUpdated     Type = "image.updated"
+	ImageDeleted     Type = "image.deleted"
+	VariantGenerated Type = "variant.generated"
+)
+
+// Event is sent as JSON body of webhook requests.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      Type           `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      map[string]any `json:"data"`
+}
+
+// NewEvent returns an event of type `t` with a random ID.
+func NewEvent(t Type, data map[string]any) *Event {
+	id := make([]byte, 16)
+	rand.Read(id) // never returns an error
+
+	return &Event{
+		ID:        hex.EncodeToString(id),
+		Type:      t,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// Sign returns the value of `X-Kriti-Signature` header for `body` i.e.
+// `sha256=<hex encoded HMAC-SHA256 of body>`. Receivers must compute the same
+// using the shared secret and compare in constant time.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
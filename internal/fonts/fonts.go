@@ -0,0 +1,94 @@
+// package fonts loads TrueType and OpenType fonts used to draw text on images.
+// Go fonts are bundled, other fonts are loaded from a directory by family name
+// i.e. file name without extension.
+package fonts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// ErrFontNotFound is returned when requested font family is neither bundled nor present in the directory
+var ErrFontNotFound = errors.New("font not found")
+
+// bundled fonts, always available
+var bundled = map[string][]byte{
+	"goregular": goregular.TTF,
+	"gobold":    gobold.TTF,
+	"goitalic":  goitalic.TTF,
+	"gomono":    gomono.TTF,
+}
+
+// familyPattern restricts family names, so that they can not escape the directory
+var familyPattern = regexp.MustCompile(`^[A-Za-z0-9_\- ]+$`)
+
+// Registry loads fonts on first use and keeps them in memory.
+type Registry struct {
+	dir           string
+	defaultFamily string
+	fonts         sync.Map // family name to *opentype.Font
+}
+
+// New returns a Registry loading fonts from `dir`, `defaultFamily` is used
+// when no family is requested. Only bundled fonts are available when `dir` is empty.
+func New(dir, defaultFamily string) *Registry {
+	if defaultFamily == "" {
+		defaultFamily = "goregular"
+	}
+	return &Registry{dir: dir, defaultFamily: defaultFamily}
+}
+
+// Font returns font of `family`, default font if `family` is empty. Fonts in
+// the directory are looked up as `<family>.ttf` and `<family>.otf`.
+func (r *Registry) Font(family string) (*opentype.Font, error) {
+	if family == "" {
+		family = r.defaultFamily
+	}
+	if font, ok := r.fonts.Load(family); ok {
+		return font.(*opentype.Font), nil
+	}
+	if !familyPattern.MatchString(family) {
+		return nil, fmt.Errorf("invalid font family: %s", family)
+	}
+
+	data, err := r.read(family)
+	if err != nil {
+		return nil, err
+	}
+	font, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", family, err)
+	}
+
+	r.fonts.Store(family, font)
+	return font, nil
+}
+
+func (r *Registry) read(family string) ([]byte, error) {
+	if r.dir != "" {
+		for _, ext := range []string{".ttf", ".otf"} {
+			data, err := os.ReadFile(filepath.Join(r.dir, family+ext))
+			if err == nil {
+				return data, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read font %s: %w", family, err)
+			}
+		}
+	}
+
+	if data, ok := bundled[family]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFontNotFound, family)
+}
@@ -0,0 +1,39 @@
+package imagemeta
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// HasAlpha returns true when any pixel of the image is not fully opaque.
+func HasAlpha(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DominantColors returns at most `count` most frequent colors of the image,
+// most frequent first, see Palette.
+func DominantColors(img image.Image, count int) []color.RGBA {
+	colors := make([]color.RGBA, 0, count)
+	for _, swatch := range Palette(img, count) {
+		colors = append(colors, swatch.Color)
+	}
+	return colors
+}
+
+// Hex returns color as `#rrggbb`.
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,240 @@
+// package imagemeta reads metadata of encoded images which is not exposed by
+// image decoders, e.g. EXIF orientation and ICC color profile, and analyses
+// decoded images e.g. for transparency and dominant colors.
+package imagemeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// containers of metadata blocks in supported formats
+var (
+	jpegExifHeader = []byte("Exif\x00\x00")
+	jpegICCHeader  = []byte("ICC_PROFILE\x00")
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Orientation returns EXIF orientation (1 to 8) of the encoded image, 1 i.e.
+// no rotation when the image has no EXIF data.
+func Orientation(data []byte) int {
+	exif := exifData(data)
+	if exif == nil {
+		return 1
+	}
+
+	orientation := tiffOrientation(exif)
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// ColorProfile returns description of the embedded ICC profile e.g. "Display P3",
+// "sRGB" for PNG images marked as sRGB and empty when no profile is embedded.
+func ColorProfile(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		var profile []byte
+		for _, segment := range jpegSegments(data, 0xe2) {
+			// ICC_PROFILE header, sequence number and count of chunks precede the profile
+			if bytes.HasPrefix(segment, jpegICCHeader) && len(segment) > len(jpegICCHeader)+2 {
+				profile = append(profile, segment[len(jpegICCHeader)+2:]...)
+			}
+		}
+		return iccDescription(profile)
+	case bytes.HasPrefix(data, pngSignature):
+		if chunk := pngChunk(data, "iCCP"); chunk != nil {
+			// profile name, null separator and compression method precede the zlib stream
+			name, compressed, ok := bytes.Cut(chunk, []byte{0})
+			if !ok || len(compressed) < 1 {
+				return ""
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(compressed[1:]))
+			if err != nil {
+				return string(name)
+			}
+			defer reader.Close()
+			profile, err := io.ReadAll(io.LimitReader(reader, 4<<20))
+			if description := iccDescription(profile); err == nil && description != "" {
+				return description
+			}
+			return string(name)
+		}
+		if pngChunk(data, "sRGB") != nil {
+			return "sRGB"
+		}
+	case isWebP(data):
+		return iccDescription(webpChunk(data, "ICCP"))
+	}
+	return ""
+}
+
+// exifData returns the TIFF structure of EXIF data embedded in the image.
+func exifData(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		for _, segment := range jpegSegments(data, 0xe1) {
+			if bytes.HasPrefix(segment, jpegExifHeader) {
+				return segment[len(jpegExifHeader):]
+			}
+		}
+	case bytes.HasPrefix(data, pngSignature):
+		return pngChunk(data, "eXIf")
+	case isWebP(data):
+		exif := webpChunk(data, "EXIF")
+		// some encoders keep the JPEG header in the chunk
+		return bytes.TrimPrefix(exif, jpegExifHeader)
+	}
+	return nil
+}
+
+// tiffOrientation returns value of Orientation tag (0x0112) in IFD0, 0 if absent.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := range entries {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			// SHORT value is stored in the first two bytes of value field
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// iccDescription returns text of `desc` tag of ICC profile, supports both
+// textDescriptionType (ICC v2) and multiLocalizedUnicodeType (ICC v4).
+func iccDescription(profile []byte) string {
+	if len(profile) < 132 {
+		return ""
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[128:132]))
+	for i := range count {
+		entry := 132 + i*12
+		if entry+12 > len(profile) {
+			return ""
+		}
+		if string(profile[entry:entry+4]) != "desc" {
+			continue
+		}
+
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset < 0 || size < 12 || offset+size > len(profile) {
+			return ""
+		}
+		tag := profile[offset : offset+size]
+
+		switch string(tag[:4]) {
+		case "desc":
+			length := int(binary.BigEndian.Uint32(tag[8:12]))
+			if 12+length > len(tag) {
+				return ""
+			}
+			return strings.TrimRight(string(tag[12:12+length]), "\x00")
+		case "mluc":
+			// first record is used, records hold language, country, length and offset
+			if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:12]) == 0 {
+				return ""
+			}
+			length := int(binary.BigEndian.Uint32(tag[20:24]))
+			start := int(binary.BigEndian.Uint32(tag[24:28]))
+			if start+length > len(tag) {
+				return ""
+			}
+			text := make([]uint16, length/2)
+			for j := range text {
+				text[j] = binary.BigEndian.Uint16(tag[start+j*2:])
+			}
+			return strings.TrimRight(string(utf16.Decode(text)), "\x00")
+		}
+		return ""
+	}
+	return ""
+}
+
+// jpegSegments returns payloads of JPEG segments with given APPn `marker`,
+// scanning stops at start of scan.
+func jpegSegments(data []byte, marker byte) [][]byte {
+	segments := make([][]byte, 0)
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			break
+		}
+		current := data[i+1]
+		if current == 0xda || current == 0xd9 { // start of scan, end of image
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		if current == marker {
+			segments = append(segments, data[i+4:i+2+length])
+		}
+		i += 2 + length
+	}
+	return segments
+}
+
+// pngChunk returns data of the first PNG chunk of `chunkType`, chunks after
+// image data are not scanned.
+func pngChunk(data []byte, chunkType string) []byte {
+	for i := len(pngSignature); i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		current := string(data[i+4 : i+8])
+		if length < 0 || i+12+length > len(data) || current == "IDAT" {
+			return nil
+		}
+		if current == chunkType {
+			return data[i+8 : i+8+length]
+		}
+		i += 12 + length // length, type, data and CRC
+	}
+	return nil
+}
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// webpChunk returns data of the first RIFF chunk of `chunkType` in a WebP image.
+func webpChunk(data []byte, chunkType string) []byte {
+	for i := 12; i+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if length < 0 || i+8+length > len(data) {
+			return nil
+		}
+		if string(data[i:i+4]) == chunkType {
+			return data[i+8 : i+8+length]
+		}
+		i += 8 + length + length%2 // chunks are padded to even size
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var jpegData, pngData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		data         []byte
+		orientation  int
+		colorProfile string
+	}{
+		{
+			name:        "jpeg without metadata",
+			data:        jpegData.Bytes(),
+			orientation: 1,
+		},
+		{
+			name:         "jpeg with exif and icc profile",
+			data:         withJPEGSegments(jpegData.Bytes(), exifSegment(6), iccSegment("Display P3")),
+			orientation:  6,
+			colorProfile: "Display P3",
+		},
+		{
+			name:        "png without metadata",
+			data:        pngData.Bytes(),
+			orientation: 1,
+		},
+		{
+			name:        "invalid data",
+			data:        []byte("not an image"),
+			orientation: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if orientation := Orientation(tt.data); orientation != tt.orientation {
+				t.Errorf("Expected orientation %d, got %d", tt.orientation, orientation)
+			}
+			if profile := ColorProfile(tt.data); profile != tt.colorProfile {
+				t.Errorf("Expected color profile %q, got %q", tt.colorProfile, profile)
+			}
+		})
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := range 10 {
+		for x := range 10 {
+			switch {
+			case x < 7:
+				img.Set(x, y, color.NRGBA{200, 0, 0, 255})
+			case x < 9:
+				img.Set(x, y, color.NRGBA{0, 0, 200, 255})
+			}
+		}
+	}
+
+	colors := DominantColors(img, 5)
+	if len(colors) != 2 || Hex(colors[0]) != "#c80000" || Hex(colors[1]) != "#0000c8" {
+		t.Errorf("Expected [#c80000 #0000c8], got %v", colors)
+	}
+	if !HasAlpha(img) {
+		t.Errorf("Expected image with transparent pixels to have alpha")
+	}
+}
+
+// withJPEGSegments inserts segments right after start of image marker
+func withJPEGSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xff, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // entries
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // padding and next IFD
+	return jpegSegment(0xe1, append(append([]byte{}, jpegExifHeader...), tiff...))
+}
+
+func iccSegment(description string) []byte {
+	tag := []byte("desc\x00\x00\x00\x00")
+	tag = binary.BigEndian.AppendUint32(tag, uint32(len(description)+1))
+	tag = append(tag, description...)
+	tag = append(tag, 0)
+
+	profile := make([]byte, 128)
+	profile = binary.BigEndian.AppendUint32(profile, 1) // tag count
+	profile = append(profile, "desc"...)
+	profile = binary.BigEndian.AppendUint32(profile, 144)
+	profile = binary.BigEndian.AppendUint32(profile, uint32(len(tag)))
+	profile = append(profile, tag...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+
+	payload := append(append([]byte{}, jpegICCHeader...), 1, 1) // chunk 1 of 1
+	return jpegSegment(0xe2, append(payload, profile...))
+}
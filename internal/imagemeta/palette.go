@@ -0,0 +1,109 @@
+package imagemeta
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// maxSamples limits pixels sampled along each axis while analysing colors
+const maxSamples = 100
+
+// Swatch is a color of the palette and fraction of pixels it represents.
+type Swatch struct {
+	Color      color.RGBA
+	Population float64 // 0 to 1
+}
+
+// Palette returns at most `count` colors representing the image using median
+// cut quantization, most frequent first. Fully transparent pixels are ignored.
+func Palette(img image.Image, count int) []Swatch {
+	pixels := samplePixels(img)
+	if len(pixels) == 0 || count < 1 {
+		return []Swatch{}
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < count {
+		// split box with the widest channel, boxes of a single color can not be split
+		index, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			c, width := widestChannel(box)
+			if width > widest {
+				index, channel, widest = i, c, width
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		slices.SortStableFunc(box, func(a, b [3]uint8) int { return cmp.Compare(a[channel], b[channel]) })
+		// cut where values change, so that pixels of a color stay in the same box
+		median := slices.IndexFunc(box, func(pixel [3]uint8) bool { return pixel[channel] >= box[len(box)/2][channel] })
+		if median == 0 {
+			median = slices.IndexFunc(box, func(pixel [3]uint8) bool { return pixel[channel] > box[0][channel] })
+		}
+		boxes = append(boxes[:index], append([][][3]uint8{box[:median], box[median:]}, boxes[index+1:]...)...)
+	}
+
+	swatches := make([]Swatch, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, pixel := range box {
+			r += int(pixel[0])
+			g += int(pixel[1])
+			b += int(pixel[2])
+		}
+		swatches = append(swatches, Swatch{
+			Color:      color.RGBA{uint8(r / len(box)), uint8(g / len(box)), uint8(b / len(box)), 255},
+			Population: float64(len(box)) / float64(len(pixels)),
+		})
+	}
+	slices.SortStableFunc(swatches, func(a, b Swatch) int {
+		if a.Population != b.Population {
+			return cmp.Compare(b.Population, a.Population)
+		}
+		return cmp.Compare(Hex(a.Color), Hex(b.Color))
+	})
+	return swatches
+}
+
+// samplePixels returns colors of at most maxSamples x maxSamples non transparent pixels.
+func samplePixels(img image.Image) [][3]uint8 {
+	bounds := img.Bounds()
+	stepX := max(bounds.Dx()/maxSamples, 1)
+	stepY := max(bounds.Dy()/maxSamples, 1)
+
+	pixels := make([][3]uint8, 0, min(bounds.Dx(), maxSamples)*min(bounds.Dy(), maxSamples))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A != 0 {
+				pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+			}
+		}
+	}
+	return pixels
+}
+
+// widestChannel returns the channel with largest range of values in `box` and the range.
+func widestChannel(box [][3]uint8) (int, int) {
+	low := [3]uint8{255, 255, 255}
+	high := [3]uint8{}
+	for _, pixel := range box {
+		for c := range 3 {
+			low[c] = min(low[c], pixel[c])
+			high[c] = max(high[c], pixel[c])
+		}
+	}
+
+	channel, width := 0, 0
+	for c := range 3 {
+		if int(high[c])-int(low[c]) > width {
+			channel, width = c, int(high[c])-int(low[c])
+		}
+	}
+	return channel, width
+}
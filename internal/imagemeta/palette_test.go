@@ -0,0 +1,89 @@
+package imagemeta
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	red := color.RGBA{200, 0, 0, 255}
+	darkRed := color.RGBA{100, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	// 10 columns: 5 red, 3 blue and 2 dark red, last row is transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 5))
+	for x := range 10 {
+		c := red
+		if x >= 5 && x < 8 {
+			c = blue
+		} else if x >= 8 {
+			c = darkRed
+		}
+		for y := range 4 {
+			img.Set(x, y, c)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		count    int
+		expected []Swatch
+	}{
+		{
+			name:     "single box is the average",
+			count:    1,
+			expected: []Swatch{{color.RGBA{120, 0, 76, 255}, 1}},
+		},
+		{
+			// blue is the widest channel, blue pixels are cut from reds at the median
+			name:     "split on widest channel",
+			count:    2,
+			expected: []Swatch{{color.RGBA{171, 0, 0, 255}, 0.7}, {blue, 0.3}},
+		},
+		{
+			// reds are split on red channel next, without splitting a color
+			name:     "ordered by population",
+			count:    3,
+			expected: []Swatch{{red, 0.5}, {blue, 0.3}, {darkRed, 0.2}},
+		},
+		{
+			name:     "limited to colors of the image",
+			count:    5,
+			expected: []Swatch{{red, 0.5}, {blue, 0.3}, {darkRed, 0.2}},
+		},
+		{
+			name:     "no colors",
+			count:    0,
+			expected: []Swatch{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			palette := Palette(img, tt.count)
+			if len(palette) != len(tt.expected) {
+				t.Fatalf("Expected %d colors, got %v", len(tt.expected), palette)
+			}
+			for i, swatch := range palette {
+				if swatch.Color != tt.expected[i].Color {
+					t.Errorf("Expected color %d to be %v, got %v", i, tt.expected[i].Color, swatch.Color)
+				}
+				if diff := swatch.Population - tt.expected[i].Population; diff > 1e-9 || diff < -1e-9 {
+					t.Errorf("Expected population %d to be %v, got %v", i, tt.expected[i].Population, swatch.Population)
+				}
+			}
+		})
+	}
+}
+
+func TestPaletteOrdersTiesByColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{0, 0, 200, 255})
+	img.Set(1, 0, color.RGBA{200, 0, 0, 255})
+
+	palette := Palette(img, 2)
+	if len(palette) != 2 || Hex(palette[0].Color) != "#0000c8" || Hex(palette[1].Color) != "#c80000" {
+		t.Errorf("Expected [#0000c8 #c80000] for equal populations, got %v", palette)
+	}
+}
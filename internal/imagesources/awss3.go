@@ -3,15 +3,23 @@ package imagesources
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/chai2010/webp"
 )
 
@@ -22,40 +30,45 @@ type ImageSourceS3 struct {
 }
 
 func (i *ImageSourceS3) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
+
+func (i *ImageSourceS3) ReadImage(ctx context.Context, fileName string) ([]byte, error) {
 	cleanPath := filepath.Clean(fileName)
 	if strings.Contains(cleanPath, "..") {
-		return nil, "", fmt.Errorf("invalid image path")
+		return nil, fmt.Errorf("invalid image path")
 	}
 
 	resp, err := i.Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(i.Bucket),
 		Key:    aws.String(cleanPath),
 	})
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to get image from S3: %w", err)
+	if isS3NotFound(err) {
+		return nil, fmt.Errorf("%w in S3: %w", ErrImageNotFound, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from S3: %w", err)
 	}
 	defer resp.Body.Close()
 
 	buf := new(bytes.Buffer)
 	_, err = buf.ReadFrom(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read image data: %w", err)
+		return nil, fmt.Errorf("failed to read image data: %w", err)
 	}
 
 	if err := validateImageSize(int64(buf.Len()), i.MaxFileSizeInBytes); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	if err := validateImageHeader(buf.Bytes(), &i.SourceImageValidations); err != nil {
+		return nil, err
 	}
 
-	if err := validateImageDimensions(img.Bounds().Dx(), img.Bounds().Dy(), i.MaxImageDimension); err != nil {
-		return nil, "", err
-	}
-
-	return img, format, nil
+	return buf.Bytes(), nil
 }
 
 func (i *ImageSourceS3) UploadImage(ctx context.Context, fileName string, file image.Image) error {
@@ -102,3 +115,236 @@ func (i *ImageSourceS3) UploadImage(ctx context.Context, fileName string, file i
 
 	return nil
 }
+
+func (i *ImageSourceS3) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	data, err := validateImageStream(data, size, fileName, &i.SourceImageValidations)
+	if err != nil {
+		return err
+	}
+
+	// spool the image to a temporary file rather than memory, checksum, request
+	// signing and retries need a seekable body
+	spool, err := os.CreateTemp("", "kriti-s3-upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	written, err := io.Copy(spool, data)
+	if err != nil {
+		return fmt.Errorf("failed to read image data: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	_, err = i.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(i.Bucket),
+		Key:           aws.String(cleanPath),
+		Body:          spool,
+		ContentLength: aws.Int64(written),
+		ContentType:   aws.String(contentTypeFromExt(filepath.Ext(fileName))),
+		// stored checksum is returned as content hash by StatImage
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload image to S3: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceS3) DeleteImage(ctx context.Context, fileName string) error {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	// S3 deletes are idempotent, check existence to report missing images
+	_, err := i.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(i.Bucket),
+		Key:    aws.String(cleanPath),
+	})
+	if isS3NotFound(err) {
+		return ErrImageNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get image from S3: %w", err)
+	}
+
+	_, err = i.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(i.Bucket),
+		Key:    aws.String(cleanPath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete image from S3: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceS3) VersionImage(ctx context.Context, fileName string) (*ImageVersion, error) {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	head, err := i.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(i.Bucket),
+		Key:    aws.String(cleanPath),
+	})
+	if isS3NotFound(err) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from S3: %w", err)
+	}
+
+	return &ImageVersion{
+		ETag:    aws.ToString(head.ETag),
+		ModTime: aws.ToTime(head.LastModified).UTC(),
+	}, nil
+}
+
+func (i *ImageSourceS3) StatImage(ctx context.Context, fileName string) (*ImageStat, error) {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	head, err := i.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(i.Bucket),
+		Key:          aws.String(cleanPath),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if isS3NotFound(err) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from S3: %w", err)
+	}
+
+	// only the header is needed for dimensions & format, whole object is read
+	// when it is beyond the first bytes e.g. after large ICC or XMP segments
+	config, format, err := i.decodeConfig(ctx, cleanPath, aws.String(fmt.Sprintf("bytes=0-%d", imageHeaderSize-1)))
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		config, format, err = i.decodeConfig(ctx, cleanPath, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentHash := "etag:" + strings.Trim(aws.ToString(head.ETag), `"`)
+	if head.ChecksumSHA256 != nil {
+		if sum, err := base64.StdEncoding.DecodeString(*head.ChecksumSHA256); err == nil {
+			contentHash = "sha256:" + hex.EncodeToString(sum)
+		}
+	}
+
+	return &ImageStat{
+		Name:        cleanPath,
+		Size:        aws.ToInt64(head.ContentLength),
+		Width:       config.Width,
+		Height:      config.Height,
+		Format:      format,
+		ModTime:     aws.ToTime(head.LastModified).UTC(),
+		ContentHash: contentHash,
+	}, nil
+}
+
+// decodeConfig decodes dimensions & format of the object at `key`, reading
+// only `byteRange` of the object when not nil.
+func (i *ImageSourceS3) decodeConfig(ctx context.Context, key string, byteRange *string) (image.Config, string, error) {
+	resp, err := i.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(i.Bucket),
+		Key:    aws.String(key),
+		Range:  byteRange,
+	})
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("failed to get image from S3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	config, format, err := image.DecodeConfig(resp.Body)
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, err)
+	}
+	return config, format, nil
+}
+
+func (i *ImageSourceS3) ListImages(ctx context.Context, prefix, cursor string, limit int) (*ImageList, error) {
+	if strings.Contains(prefix, "..") {
+		return nil, fmt.Errorf("invalid prefix")
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(i.Bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
+	}
+
+	resp, err := i.Client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images from S3: %w", err)
+	}
+
+	list := &ImageList{
+		Images:     make([]ImageListItem, 0, len(resp.Contents)),
+		NextCursor: aws.ToString(resp.NextContinuationToken),
+	}
+	for _, obj := range resp.Contents {
+		list.Images = append(list.Images, ImageListItem{
+			Name:    aws.ToString(obj.Key),
+			Size:    aws.ToInt64(obj.Size),
+			ModTime: aws.ToTime(obj.LastModified).UTC(),
+		})
+	}
+
+	return list, nil
+}
+
+func (i *ImageSourceS3) PresignUpload(ctx context.Context, fileName, contentType string, size int64, expires time.Duration) (*PresignedUpload, error) {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	// content type and length are part of the signature, S3 rejects uploads not matching them
+	req, err := s3.NewPresignClient(i.Client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(i.Bucket),
+		Key:           aws.String(cleanPath),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign S3 upload: %w", err)
+	}
+
+	headers := make(map[string]string, len(req.SignedHeader))
+	for key := range req.SignedHeader {
+		if key != "Host" {
+			headers[key] = req.SignedHeader.Get(key)
+		}
+	}
+	headers[http.CanonicalHeaderKey("Content-Type")] = contentType
+
+	return &PresignedUpload{
+		URL:       req.URL,
+		Method:    req.Method,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(expires).UTC(),
+	}, nil
+}
+
+// isS3NotFound returns true if `err` reports a missing S3 object
+func isS3NotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	return errors.As(err, &notFound) || errors.As(err, &noSuchKey)
+}
@@ -0,0 +1,55 @@
+package imagesources
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestS3PutImageStreamsBody(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var path string
+	var received []byte
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentLength = r.URL.Path, r.ContentLength
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	source := &ImageSourceS3{
+		SourceImageValidations: SourceImageValidations{MaxImageDimension: 10, MaxFileSizeInBytes: 1024},
+		Bucket:                 "images",
+		Client: s3.New(s3.Options{
+			Region:       "us-east-1",
+			BaseEndpoint: aws.String(server.URL),
+			UsePathStyle: true,
+			Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+			}),
+		}),
+	}
+
+	if err := source.PutImage(context.Background(), "up/a.png", bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if path != "/images/up/a.png" {
+		t.Errorf("Expected object /images/up/a.png, got %s", path)
+	}
+	if contentLength != int64(buf.Len()) || !bytes.Equal(received, buf.Bytes()) {
+		t.Errorf("Expected %d bytes of the image, got %d with Content-Length %d", buf.Len(), len(received), contentLength)
+	}
+}
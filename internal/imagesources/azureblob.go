@@ -0,0 +1,146 @@
+package imagesources
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// ImageSourceAzureBlob represents an Azure Blob Storage container as an image source.
+type ImageSourceAzureBlob struct {
+	SourceImageValidations
+	Container string
+	Client    *azblob.Client
+}
+
+func (i *ImageSourceAzureBlob) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
+
+func (i *ImageSourceAzureBlob) ReadImage(ctx context.Context, fileName string) ([]byte, error) {
+	cleanPath := filepath.ToSlash(filepath.Clean(fileName))
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	resp, err := i.Client.DownloadStream(ctx, i.Container, cleanPath, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return nil, fmt.Errorf("%w in Azure Blob Storage: %w", ErrImageNotFound, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from Azure Blob Storage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// reject early using blob properties, avoids downloading huge blobs
+	if resp.ContentLength != nil {
+		if err := validateImageSize(*resp.ContentLength, i.MaxFileSizeInBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	if err := validateImageSize(int64(buf.Len()), i.MaxFileSizeInBytes); err != nil {
+		return nil, err
+	}
+
+	if err := validateImageHeader(buf.Bytes(), &i.SourceImageValidations); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (i *ImageSourceAzureBlob) UploadImage(ctx context.Context, fileName string, file image.Image) error {
+	cleanPath := filepath.ToSlash(filepath.Clean(fileName))
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	if err := validateImageDimensions(file.Bounds().Dx(), file.Bounds().Dy(), i.MaxImageDimension); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, file, fileName); err != nil {
+		return err
+	}
+
+	if err := validateImageSize(int64(buf.Len()), i.MaxFileSizeInBytes); err != nil {
+		return err
+	}
+
+	_, err := i.Client.UploadBuffer(ctx, i.Container, cleanPath, buf.Bytes(), &azblob.UploadBufferOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: to.Ptr(contentTypeFromExt(filepath.Ext(fileName))),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload image to Azure Blob Storage: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceAzureBlob) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	cleanPath := filepath.ToSlash(filepath.Clean(fileName))
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	data, err := validateImageStream(data, size, fileName, &i.SourceImageValidations)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.Client.UploadStream(ctx, i.Container, cleanPath, data, &azblob.UploadStreamOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: to.Ptr(contentTypeFromExt(filepath.Ext(fileName))),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload image to Azure Blob Storage: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceAzureBlob) VersionImage(ctx context.Context, fileName string) (*ImageVersion, error) {
+	cleanPath := filepath.ToSlash(filepath.Clean(fileName))
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	props, err := i.Client.ServiceClient().NewContainerClient(i.Container).NewBlobClient(cleanPath).GetProperties(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from Azure Blob Storage: %w", err)
+	}
+
+	version := &ImageVersion{}
+	if props.ETag != nil {
+		version.ETag = string(*props.ETag)
+	}
+	if props.LastModified != nil {
+		version.ModTime = props.LastModified.UTC()
+	}
+	return version, nil
+}
@@ -3,23 +3,81 @@
 package imagesources
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/chai2010/webp"
 )
 
+var (
+	// ErrImageNotFound is returned when requested image is not present in the source
+	ErrImageNotFound = errors.New("source image not found")
+	// ErrImageTooLarge is returned when file size of the image exceeds the max allowed size
+	ErrImageTooLarge = errors.New("image file too large")
+	// ErrImageDimensionsTooLarge is returned when image dimensions exceed the max allowed dimension
+	ErrImageDimensionsTooLarge = errors.New("image dimensions too large")
+	// ErrInvalidImage is returned when content is not a supported image or does not match its extension
+	ErrInvalidImage = errors.New("invalid image")
+)
+
 type SourceImageValidations struct {
 	MaxImageDimension  int
 	MaxFileSizeInBytes int64
 }
 
+// ImageStat holds metadata of an image stored in a source.
+type ImageStat struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"` // in bytes
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Format      string    `json:"format"`
+	ModTime     time.Time `json:"modified_at"`
+	ContentHash string    `json:"content_hash"` // prefixed with algorithm, e.g. sha256:<hex>
+}
+
+// PresignedUpload is a request which uploads an image directly to the storage,
+// without passing bytes through the server.
+type PresignedUpload struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"` // must be sent as is with the request
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// ImageVersion identifies the content of a stored image, it changes whenever the image is modified.
+type ImageVersion struct {
+	ETag    string    // opaque value e.g. S3 ETag, GCS generation
+	ModTime time.Time // last modified time
+}
+
+// ImageListItem represents an image returned when listing images of a source.
+type ImageListItem struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"` // in bytes
+	ModTime time.Time `json:"modified_at"`
+}
+
+// ImageList is a page of images, NextCursor is empty when there are no more pages.
+type ImageList struct {
+	Images     []ImageListItem `json:"images"`
+	NextCursor string          `json:"next_cursor"`
+}
+
 // TODO: add other S3 compatible sources
 
 // ImageSourceLocal represents the machine's local disk as an image source.
@@ -29,40 +87,43 @@ type ImageSourceLocal struct {
 }
 
 func (i *ImageSourceLocal) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
+
+func (i *ImageSourceLocal) ReadImage(ctx context.Context, fileName string) ([]byte, error) {
 	// Ensure the path is safe and doesn't contain directory traversal
 	cleanPath := filepath.Clean(fileName)
 	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
-		return nil, "", fmt.Errorf("invalid image path")
+		return nil, fmt.Errorf("invalid image path")
 	}
 
 	fullPath := filepath.Join(i.BasePath, cleanPath)
 
-	_, err := os.Stat(fullPath)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to stat image: %w", err)
-	}
-
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	fileStat, err := os.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat image: %w", err)
+	} else if fileStat.IsDir() {
+		return nil, ErrImageNotFound
 	}
-	defer file.Close()
 
-	fileStat, _ := file.Stat()
 	if err := validateImageSize(fileStat.Size(), i.MaxFileSizeInBytes); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	img, format, err := image.Decode(file)
+	data, err := os.ReadFile(fullPath)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+		return nil, fmt.Errorf("failed to read image: %w", err)
 	}
-
-	if err := validateImageDimensions(img.Bounds().Dx(), img.Bounds().Dy(), i.MaxImageDimension); err != nil {
-		return nil, "", err
+	if err := validateImageHeader(data, &i.SourceImageValidations); err != nil {
+		return nil, err
 	}
-
-	return img, format, nil
+	return data, nil
 }
 
 func (i *ImageSourceLocal) UploadImage(ctx context.Context, fileName string, file image.Image) error {
@@ -125,14 +186,267 @@ func (i *ImageSourceLocal) UploadImage(ctx context.Context, fileName string, fil
 	return nil
 }
 
+func (i *ImageSourceLocal) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	// Ensure the path is safe and doesn't contain directory traversal
+	cleanPath := filepath.Clean(fileName)
+	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	data, err := validateImageStream(data, size, fileName, &i.SourceImageValidations)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the directory exists
+	fullPath := filepath.Join(i.BasePath, cleanPath)
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partially written image
+	tmpFile, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // no-op once renamed
+
+	if _, err := io.Copy(tmpFile, data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	// temporary files are private, uploads are readable like files placed by hand
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), fullPath); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceLocal) DeleteImage(ctx context.Context, fileName string) error {
+	// Ensure the path is safe and doesn't contain directory traversal
+	cleanPath := filepath.Clean(fileName)
+	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	// os.Remove deletes empty directories too, which are not images
+	fullPath := filepath.Join(i.BasePath, cleanPath)
+	info, err := os.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ErrImageNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+
+	err = os.Remove(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrImageNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceLocal) VersionImage(ctx context.Context, fileName string) (*ImageVersion, error) {
+	// Ensure the path is safe and doesn't contain directory traversal
+	cleanPath := filepath.Clean(fileName)
+	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	fileStat, err := os.Stat(filepath.Join(i.BasePath, cleanPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat image: %w", err)
+	} else if fileStat.IsDir() {
+		return nil, ErrImageNotFound
+	}
+
+	return &ImageVersion{
+		ETag:    fmt.Sprintf("%x-%x", fileStat.ModTime().UnixNano(), fileStat.Size()),
+		ModTime: fileStat.ModTime().UTC(),
+	}, nil
+}
+
+func (i *ImageSourceLocal) StatImage(ctx context.Context, fileName string) (*ImageStat, error) {
+	// Ensure the path is safe and doesn't contain directory traversal
+	cleanPath := filepath.Clean(fileName)
+	if filepath.IsAbs(cleanPath) || strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	file, err := os.Open(filepath.Join(i.BasePath, cleanPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	fileStat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat image: %w", err)
+	} else if fileStat.IsDir() {
+		return nil, ErrImageNotFound
+	}
+
+	// hash the complete file while DecodeConfig reads only the header
+	hash := sha256.New()
+	config, format, err := image.DecodeConfig(io.TeeReader(file, hash))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, err)
+	}
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	return &ImageStat{
+		Name:        filepath.ToSlash(cleanPath),
+		Size:        fileStat.Size(),
+		Width:       config.Width,
+		Height:      config.Height,
+		Format:      format,
+		ModTime:     fileStat.ModTime().UTC(),
+		ContentHash: "sha256:" + hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func (i *ImageSourceLocal) ListImages(ctx context.Context, prefix, cursor string, limit int) (*ImageList, error) {
+	cleanPrefix := filepath.ToSlash(prefix)
+	if filepath.IsAbs(cleanPrefix) || strings.Contains(cleanPrefix, "..") {
+		return nil, fmt.Errorf("invalid prefix")
+	}
+
+	// walk only the directory containing the prefix, names are filtered later
+	dir := ""
+	if idx := strings.LastIndex(cleanPrefix, "/"); idx >= 0 {
+		dir = cleanPrefix[:idx+1]
+	}
+
+	items := make([]ImageListItem, 0, limit+1)
+	err := i.walkImages(dir, func(name string, d fs.DirEntry) error {
+		if d.IsDir() {
+			// skip directories without names matching prefix or after cursor
+			matches := strings.HasPrefix(name, cleanPrefix) || strings.HasPrefix(cleanPrefix, name)
+			if !matches || (name <= cursor && !strings.HasPrefix(cursor, name)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || formatFromExt(filepath.Ext(name)) == "" {
+			return nil
+		}
+		if !strings.HasPrefix(name, cleanPrefix) || name <= cursor {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, ImageListItem{Name: name, Size: info.Size(), ModTime: info.ModTime().UTC()})
+		if len(items) > limit {
+			// one more than the page tells that there is a next page
+			return errPageFilled
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, errPageFilled) {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	list := &ImageList{Images: items}
+	if len(items) > limit {
+		list.Images = items[:limit]
+		list.NextCursor = items[limit-1].Name
+	}
+	return list, nil
+}
+
+// errPageFilled stops walking images once a page of ListImages is filled
+var errPageFilled = errors.New("page filled")
+
+// walkImages calls `fn` for entries under directory `dir` of base path, e.g.
+// "uploads/" or empty for base path, with names relative to base path. Entries
+// are visited in plain string order of their names, which is the order of the
+// list cursor, so that listing can stop once a page is filled. Directories are
+// named with a trailing slash, fs.SkipDir returned for them skips their entries;
+// any other error stops the walk and is returned.
+func (i *ImageSourceLocal) walkImages(dir string, fn func(name string, d fs.DirEntry) error) error {
+	entries, err := os.ReadDir(filepath.Join(i.BasePath, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+
+	// names of directories end with "/", e.g. "a/" sorts after "a-b.jpg"
+	name := func(d fs.DirEntry) string {
+		if d.IsDir() {
+			return dir + d.Name() + "/"
+		}
+		return dir + d.Name()
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(name(a), name(b)) })
+
+	for _, entry := range entries {
+		err := fn(name(entry), entry)
+		if entry.IsDir() && errors.Is(err, fs.SkipDir) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if err := i.walkImages(name(entry), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////
 // Util functions for all image sources
 ///////////////////////////////////////////////////////////////////////////////////////////////
 
+// validateImageHeader validates dimensions of encoded image in `data` using its
+// header, so that images are checked before decoding them
+func validateImageHeader(data []byte, validations *SourceImageValidations) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, err)
+	}
+	return validateImageDimensions(config.Width, config.Height, validations.MaxImageDimension)
+}
+
+// decodeImage decodes `data` and validates dimensions of the image
+func decodeImage(data []byte, validations *SourceImageValidations) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, err)
+	}
+
+	if err := validateImageDimensions(img.Bounds().Dx(), img.Bounds().Dy(), validations.MaxImageDimension); err != nil {
+		return nil, "", err
+	}
+
+	return img, format, nil
+}
+
 // validateImageDimensions returns error if the image dimensions exceed max allowed dimensions
 func validateImageDimensions(width, height, max int) error {
 	if width > max || height > max {
-		return fmt.Errorf("image dimensions too large: max allowed is %dx%d", max, max)
+		return fmt.Errorf("%w: max allowed is %dx%d", ErrImageDimensionsTooLarge, max, max)
 	}
 	return nil
 }
@@ -140,7 +454,137 @@ func validateImageDimensions(width, height, max int) error {
 // validateImageSize returns error if size of the image file is more than max allowed file size
 func validateImageSize(fileSize, max int64) error {
 	if fileSize > max {
-		return fmt.Errorf("image file too large: max allowed is %d bytes", max)
+		return fmt.Errorf("%w: max allowed is %d bytes", ErrImageTooLarge, max)
+	}
+	return nil
+}
+
+// EncodeImage encodes the image into `w` using the format inferred from extension
+// of `fileName`, it is how sources encode images on upload.
+func EncodeImage(w io.Writer, file image.Image, fileName string) error {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	switch ext {
+	case ".jpg", ".jpeg":
+		if err := jpeg.Encode(w, file, &jpeg.Options{Quality: 85}); err != nil {
+			return fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+	case ".png":
+		if err := png.Encode(w, file); err != nil {
+			return fmt.Errorf("failed to encode PNG: %w", err)
+		}
+	case ".webp":
+		if err := webp.Encode(w, file, &webp.Options{Quality: 85}); err != nil {
+			return fmt.Errorf("failed to encode WebP: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported image format: %s", ext)
 	}
 	return nil
 }
+
+// contentTypeFromExt returns MIME type of the image for given file extension
+func contentTypeFromExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// imageHeaderSize is the number of leading bytes first read to validate an image
+// stream, it is doubled until image header is found e.g. when large EXIF, ICC
+// or XMP segments precede JPEG frame header.
+const imageHeaderSize = 256 * 1024
+
+// validateImageStream validates the image in `data` without decoding it fully.
+// It checks file size, sniffs magic bytes to ensure the content matches extension
+// of `fileName` and validates dimensions using image.DecodeConfig.
+//
+// Returned reader yields the complete, unmodified image data and must be used
+// in place of `data`.
+func validateImageStream(data io.Reader, size int64, fileName string, validations *SourceImageValidations) (io.Reader, error) {
+	if err := validateImageSize(size, validations.MaxFileSizeInBytes); err != nil {
+		return nil, err
+	}
+
+	header, err := readImageHeader(data, nil, min(size, imageHeaderSize))
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := SniffImageFormat(header)
+	if err != nil {
+		return nil, err
+	}
+	if expected := formatFromExt(filepath.Ext(fileName)); expected != format {
+		return nil, fmt.Errorf("%w: content (%s) does not match file extension %q", ErrInvalidImage, format, filepath.Ext(fileName))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(header))
+	for errors.Is(err, io.ErrUnexpectedEOF) && int64(len(header)) < size {
+		// header is beyond bytes read so far, read as many again
+		read := len(header)
+		if header, err = readImageHeader(data, header, min(size, 2*int64(read))); err != nil {
+			return nil, err
+		}
+		if len(header) == read {
+			// data ended before declared size
+			return nil, fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, io.ErrUnexpectedEOF)
+		}
+		config, _, err = image.DecodeConfig(bytes.NewReader(header))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode image: %w", ErrInvalidImage, err)
+	}
+	if err := validateImageDimensions(config.Width, config.Height, validations.MaxImageDimension); err != nil {
+		return nil, err
+	}
+
+	// never read more than declared size, guards against wrong size from callers
+	return io.MultiReader(bytes.NewReader(header), io.LimitReader(data, size-int64(len(header)))), nil
+}
+
+// readImageHeader reads from `data` until `header` has `length` bytes or data ends.
+func readImageHeader(data io.Reader, header []byte, length int64) ([]byte, error) {
+	read := len(header)
+	header = append(header, make([]byte, length-int64(read))...)
+	n, err := io.ReadFull(data, header[read:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+	return header[:read+n], nil
+}
+
+// SniffImageFormat returns the image format (jpeg, png or webp) detected using
+// magic bytes at the start of `header`.
+func SniffImageFormat(header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg", nil
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", nil
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return "webp", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported or invalid image content", ErrInvalidImage)
+	}
+}
+
+// formatFromExt returns the image format for given file extension, empty if not supported
+func formatFromExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".webp":
+		return "webp"
+	default:
+		return ""
+	}
+}
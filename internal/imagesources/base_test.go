@@ -0,0 +1,161 @@
+package imagesources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newTestLocalSource(t *testing.T, files ...string) *ImageSourceLocal {
+	t.Helper()
+	basePath := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(basePath, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("image"), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return &ImageSourceLocal{BasePath: basePath}
+}
+
+func TestLocalListImages(t *testing.T) {
+	// "a/" sorts after "a-c.jpg" as plain strings, while directory "a" is read first
+	source := newTestLocalSource(t, "a-c.jpg", "a/b.jpg", "a/d/e.png", "b.webp", "a/.hidden.jpg", "a/notes.txt", "z/y.jpg")
+
+	tests := []struct {
+		name     string
+		prefix   string
+		limit    int
+		expected []string
+	}{
+		{name: "all", limit: 10, expected: []string{"a-c.jpg", "a/b.jpg", "a/d/e.png", "b.webp", "z/y.jpg"}},
+		{name: "prefix directory", prefix: "a/", limit: 10, expected: []string{"a/b.jpg", "a/d/e.png"}},
+		{name: "prefix name", prefix: "a", limit: 10, expected: []string{"a-c.jpg", "a/b.jpg", "a/d/e.png"}},
+		{name: "missing prefix directory", prefix: "missing/", limit: 10, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := source.ListImages(context.Background(), tt.prefix, "", tt.limit)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			names := make([]string, 0, len(list.Images))
+			for _, item := range list.Images {
+				names = append(names, item.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestLocalListImagesPages(t *testing.T) {
+	source := newTestLocalSource(t, "a-c.jpg", "a/b.jpg", "a/d/e.png", "b.webp", "z/y.jpg")
+	expected := []string{"a-c.jpg", "a/b.jpg", "a/d/e.png", "b.webp", "z/y.jpg"}
+
+	names, cursor := make([]string, 0), ""
+	for pages := 1; ; pages++ {
+		list, err := source.ListImages(context.Background(), "", cursor, 2)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, item := range list.Images {
+			names = append(names, item.Name)
+		}
+		if list.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("Expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		cursor = list.NextCursor
+	}
+
+	if !slices.Equal(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestLocalDeleteImage(t *testing.T) {
+	source := newTestLocalSource(t, "a/b.jpg")
+	if err := os.Mkdir(filepath.Join(source.BasePath, "empty"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		expected error
+	}{
+		{name: "empty directory", fileName: "empty", expected: ErrImageNotFound},
+		{name: "directory", fileName: "a", expected: ErrImageNotFound},
+		{name: "missing image", fileName: "a/c.jpg", expected: ErrImageNotFound},
+		{name: "image", fileName: "a/b.jpg", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := source.DeleteImage(context.Background(), tt.fileName); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected error %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(source.BasePath, "empty")); err != nil {
+		t.Errorf("Expected empty directory to be kept, got %v", err)
+	}
+}
+
+func TestLocalPutImageValidations(t *testing.T) {
+	source := newTestLocalSource(t)
+	source.MaxImageDimension = 10
+	source.MaxFileSizeInBytes = 1024
+
+	encode := func(size int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		size     int64 // declared size, length of data when 0
+		expected error
+	}{
+		{name: "valid", filename: "a.png", data: encode(4)},
+		{name: "file too large", filename: "a.png", data: encode(4), size: 2048, expected: ErrImageTooLarge},
+		{name: "dimensions too large", filename: "a.png", data: encode(20), expected: ErrImageDimensionsTooLarge},
+		{name: "extension mismatch", filename: "a.jpg", data: encode(4), expected: ErrInvalidImage},
+		{name: "not an image", filename: "a.png", data: []byte("not an image"), expected: ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			err := source.PutImage(context.Background(), tt.filename, bytes.NewReader(tt.data), size)
+			if tt.expected == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			} else if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package imagesources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// ImageSourceGCS represents a Google Cloud Storage bucket as an image source.
+type ImageSourceGCS struct {
+	SourceImageValidations
+	Bucket string
+	Client *storage.Client
+}
+
+func (i *ImageSourceGCS) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
+
+func (i *ImageSourceGCS) ReadImage(ctx context.Context, fileName string) ([]byte, error) {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	reader, err := i.Client.Bucket(i.Bucket).Object(cleanPath).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w in GCS: %w", ErrImageNotFound, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from GCS: %w", err)
+	}
+	defer reader.Close()
+
+	// reject early using object attributes, avoids downloading huge objects
+	if err := validateImageSize(reader.Attrs.Size, i.MaxFileSizeInBytes); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	if err := validateImageHeader(buf.Bytes(), &i.SourceImageValidations); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (i *ImageSourceGCS) UploadImage(ctx context.Context, fileName string, file image.Image) error {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	if err := validateImageDimensions(file.Bounds().Dx(), file.Bounds().Dy(), i.MaxImageDimension); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeImage(buf, file, fileName); err != nil {
+		return err
+	}
+
+	if err := validateImageSize(int64(buf.Len()), i.MaxFileSizeInBytes); err != nil {
+		return err
+	}
+
+	writer := i.Client.Bucket(i.Bucket).Object(cleanPath).NewWriter(ctx)
+	writer.ContentType = contentTypeFromExt(filepath.Ext(fileName))
+	if _, err := writer.Write(buf.Bytes()); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to upload image to GCS: %w", err)
+	}
+	// object is committed only when writer is closed successfully
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload image to GCS: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceGCS) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return fmt.Errorf("invalid image path")
+	}
+
+	data, err := validateImageStream(data, size, fileName, &i.SourceImageValidations)
+	if err != nil {
+		return err
+	}
+
+	writer := i.Client.Bucket(i.Bucket).Object(cleanPath).NewWriter(ctx)
+	writer.ContentType = contentTypeFromExt(filepath.Ext(fileName))
+	if _, err := io.Copy(writer, data); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to upload image to GCS: %w", err)
+	}
+	// object is committed only when writer is closed successfully
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to upload image to GCS: %w", err)
+	}
+
+	return nil
+}
+
+func (i *ImageSourceGCS) VersionImage(ctx context.Context, fileName string) (*ImageVersion, error) {
+	cleanPath := filepath.Clean(fileName)
+	if strings.Contains(cleanPath, "..") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	attrs, err := i.Client.Bucket(i.Bucket).Object(cleanPath).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrImageNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get image from GCS: %w", err)
+	}
+
+	return &ImageVersion{
+		ETag:    strconv.FormatInt(attrs.Generation, 10),
+		ModTime: attrs.Updated.UTC(),
+	}, nil
+}
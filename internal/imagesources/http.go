@@ -7,6 +7,8 @@ import (
 	"image"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 )
 
@@ -15,44 +17,104 @@ type ImageSourceHTTP struct {
 }
 
 func (i ImageSourceHTTP) GetImage(ctx context.Context, url string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, url)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
+
+func (i ImageSourceHTTP) ReadImage(ctx context.Context, url string) ([]byte, error) {
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		return nil, "", fmt.Errorf("invalid URL")
+		return nil, fmt.Errorf("invalid URL")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	return fetchImage(ctx, url, nil, &i.SourceImageValidations)
+}
+
+// UploadImage is not supported for URL source
+func (i ImageSourceHTTP) UploadImage(ctx context.Context, fileName string, file image.Image) error {
+	return fmt.Errorf("upload not supported for HTTP source")
+}
+
+// PutImage is not supported for URL source
+func (i ImageSourceHTTP) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	return fmt.Errorf("upload not supported for HTTP source")
+}
+
+// ImageSourceWebFolder represents a remote HTTP(s) folder as an image source.
+// Image paths are resolved relative to the BaseURL, e.g. with BaseURL
+// `https://assets.example.com/media/` path `a/b.jpg` is fetched from
+// `https://assets.example.com/media/a/b.jpg`.
+type ImageSourceWebFolder struct {
+	SourceImageValidations
+	BaseURL *url.URL
+	Headers map[string]string // sent with every request to origin, e.g. Authorization
+}
+
+func (i *ImageSourceWebFolder) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	data, err := i.ReadImage(ctx, fileName)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+		return nil, "", err
 	}
+	return decodeImage(data, &i.SourceImageValidations)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+func (i *ImageSourceWebFolder) ReadImage(ctx context.Context, fileName string) ([]byte, error) {
+	// Ensure the path is relative and doesn't escape the base URL
+	cleanPath := path.Clean("/" + fileName)
+	if strings.Contains(fileName, "..") || strings.Contains(fileName, "://") {
+		return nil, fmt.Errorf("invalid image path")
+	}
+
+	return fetchImage(ctx, i.BaseURL.JoinPath(cleanPath).String(), i.Headers, &i.SourceImageValidations)
+}
+
+// UploadImage is not supported for web folder source
+func (i *ImageSourceWebFolder) UploadImage(ctx context.Context, fileName string, file image.Image) error {
+	return fmt.Errorf("upload not supported for web folder source")
+}
+
+// PutImage is not supported for web folder source
+func (i *ImageSourceWebFolder) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	return fmt.Errorf("upload not supported for web folder source")
+}
+
+// fetchImage downloads the image at `url`, provided headers are added to the request
+func fetchImage(ctx context.Context, url string, headers map[string]string, validations *SourceImageValidations) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to fetch image: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
 	}
-	defer resp.Body.Close()
 
-	buf := new(bytes.Buffer)
-	n, err := io.Copy(buf, resp.Body)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read image data: %w", err)
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if err := validateImageSize(n, i.MaxFileSizeInBytes); err != nil {
-		return nil, "", err
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w at %s", ErrImageNotFound, url)
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch image: unexpected status %d", resp.StatusCode)
 	}
 
-	img, format, err := image.Decode(bytes.NewReader(buf.Bytes()))
+	buf := new(bytes.Buffer)
+	// read one byte more than allowed to detect oversized images without reading them fully
+	n, err := io.Copy(buf, io.LimitReader(resp.Body, validations.MaxFileSizeInBytes+1))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+		return nil, fmt.Errorf("failed to read image data: %w", err)
 	}
 
-	if err := validateImageDimensions(img.Bounds().Dx(), img.Bounds().Dy(), i.MaxImageDimension); err != nil {
-		return nil, "", err
+	if err := validateImageSize(n, validations.MaxFileSizeInBytes); err != nil {
+		return nil, err
+	}
+	if err := validateImageHeader(buf.Bytes(), validations); err != nil {
+		return nil, err
 	}
 
-	return img, format, nil
-}
-
-// UploadImage is not supported for URL source
-func (i ImageSourceHTTP) UploadImage(ctx context.Context, fileName string, file image.Image) error {
-	return fmt.Errorf("upload not supported for HTTP source")
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,80 @@
+package placeholder
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlurHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		img      image.Image
+		x, y     int
+		expected string
+	}{
+		{
+			name:     "black image",
+			img:      solidImage(32, 32, color.NRGBA{0, 0, 0, 255}),
+			x:        4,
+			y:        3,
+			expected: "L00000fQfQfQfQfQfQfQfQfQfQfQ",
+		},
+		{
+			name:     "single component",
+			img:      solidImage(8, 8, color.NRGBA{255, 255, 255, 255}),
+			x:        1,
+			y:        1,
+			expected: "00TSUA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hash := BlurHash(tt.img, tt.x, tt.y); hash != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, hash)
+			}
+		})
+	}
+}
+
+func TestThumbHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		img    image.Image
+		length int
+	}{
+		{
+			name:   "opaque square image",
+			img:    solidImage(32, 32, color.NRGBA{200, 100, 50, 255}),
+			length: 24, // 5 header bytes and 37 factors
+		},
+		{
+			name:   "transparent image",
+			img:    solidImage(32, 32, color.NRGBA{200, 100, 50, 0}),
+			length: 25, // 6 header bytes and 38 factors
+		},
+		{
+			name: "larger than 100x100",
+			img:  solidImage(101, 10, color.NRGBA{0, 0, 0, 255}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hash := ThumbHash(tt.img); len(hash) != tt.length {
+				t.Errorf("Expected %d bytes, got %d", tt.length, len(hash))
+			}
+		})
+	}
+}
+
+func solidImage(width, height int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
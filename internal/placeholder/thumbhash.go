@@ -0,0 +1,137 @@
+package placeholder
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// ThumbHash encodes the image as ThumbHash bytes, see https://evanw.github.io/thumbhash/.
+// Images larger than 100x100 are not supported and must be downscaled first,
+// nil is returned for them.
+func ThumbHash(img image.Image) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w < 1 || h < 1 || w > 100 || h > 100 {
+		return nil
+	}
+
+	// average color, weighted by alpha
+	rgba := make([]color.NRGBA, 0, w*h)
+	var avgR, avgG, avgB, avgA float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			alpha := float64(c.A) / 255
+			avgR += alpha / 255 * float64(c.R)
+			avgG += alpha / 255 * float64(c.G)
+			avgB += alpha / 255 * float64(c.B)
+			avgA += alpha
+			rgba = append(rgba, c)
+		}
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(w*h)
+	lLimit := 7.0
+	if hasAlpha {
+		lLimit = 5 // fewer luminance bits when alpha is encoded
+	}
+	lx := max(1, int(round(lLimit*float64(w)/float64(max(w, h)))))
+	ly := max(1, int(round(lLimit*float64(h)/float64(max(w, h)))))
+
+	// convert to LPQA i.e. luminance, yellow-blue, red-green and alpha, composited atop average color
+	l := make([]float64, w*h)
+	p := make([]float64, w*h)
+	q := make([]float64, w*h)
+	a := make([]float64, w*h)
+	for i, c := range rgba {
+		alpha := float64(c.A) / 255
+		r := avgR*(1-alpha) + alpha/255*float64(c.R)
+		g := avgG*(1-alpha) + alpha/255*float64(c.G)
+		b := avgB*(1-alpha) + alpha/255*float64(c.B)
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	// DCT into constant (DC) and normalized varying (AC) terms
+	encodeChannel := func(channel []float64, nx, ny int) (float64, []float64, float64) {
+		var dc, scale float64
+		ac := make([]float64, 0)
+		fx := make([]float64, w)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				for x := range w {
+					fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+				}
+				f := 0.0
+				for y := range h {
+					fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+					for x := range w {
+						f += channel[x+y*w] * fx[x] * fy
+					}
+				}
+				f /= float64(w * h)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return dc, ac, scale
+	}
+	lDC, lAC, lScale := encodeChannel(l, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+
+	isLandscape := w > h
+	header24 := int(round(63*lDC)) | int(round(31.5+31.5*pDC))<<6 | int(round(31.5+31.5*qDC))<<12 | int(round(31*lScale))<<18
+	header16 := int(round(63*pScale))<<3 | int(round(63*qScale))<<9
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+	hash := []byte{byte(header24), byte(header24 >> 8), byte(header24 >> 16), byte(header16), byte(header16 >> 8)}
+
+	channels := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		aDC, aAC, aScale := encodeChannel(a, 5, 5)
+		hash = append(hash, byte(int(round(15*aDC))|int(round(15*aScale))<<4))
+		channels = append(channels, aAC)
+	}
+
+	// varying factors, two 4 bit values per byte
+	start, index := len(hash), 0
+	for _, ac := range channels {
+		for _, f := range ac {
+			if start+index/2 >= len(hash) {
+				hash = append(hash, 0)
+			}
+			hash[start+index/2] |= byte(int(round(15*f)) << ((index & 1) << 2))
+			index++
+		}
+	}
+	return hash
+}
+
+// round rounds half up, same as Math.round of the reference implementation
+func round(value float64) float64 {
+	return math.Floor(value + 0.5)
+}
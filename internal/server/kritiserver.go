@@ -3,11 +3,17 @@ package server
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"google.golang.org/api/option"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
@@ -15,9 +21,16 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/monitor"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
 	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/internal/fonts"
 	"github.com/kritihq/kriti-images/internal/imagesources"
 	"github.com/kritihq/kriti-images/internal/server/routes"
+	"github.com/kritihq/kriti-images/internal/templates"
+	"github.com/kritihq/kriti-images/internal/utils"
 	"github.com/kritihq/kriti-images/pkg/kritiimages"
 )
 
@@ -26,13 +39,38 @@ func ConfigureAndGet(ctx context.Context, cfg *config.Config) (*fiber.App, *krit
 
 	sources := getImageSources(ctx, &cfg.Images)
 	service := kritiimages.New(sources, sources[cfg.Images.Source])
+	service.Watermarks = getWatermarks(&cfg.Images, sources)
+	service.Fonts = getFonts(&cfg.Images.Fonts)
+	service.Templates = getTemplates(&cfg.Images.Templates)
 
-	routes.BindRouteTransformation(server, service)
+	transformCache := getTransformCache(&cfg.Images.Cache)
+	routes.BindRouteTransformation(server, service, &cfg.Images, transformCache)
+	routes.BindRouteImageInfo(server, service, &cfg.Images)
+	routes.BindRouteTemplate(server, service, &cfg.Images, transformCache)
 
 	// NOTE: do we need upload feature?
-	// It will need auth layer to be prod ready
 	if cfg.Experimental.EnableUploadAPI {
-		routes.BindAPIUpload(server, service)
+		if cfg.API.Auth.Enabled {
+			server.Use("/api", getAuthMiddleware(&cfg.API.Auth))
+		} else {
+			log.Warn("upload APIs are enabled without authentication, set api.auth.enabled to secure them")
+		}
+		dispatcher := getEventDispatcher(&cfg.Webhooks)
+		purgers := getCDNPurgers(&cfg.CDN)
+		routes.BindAPIUpload(server, service, &cfg.API, cfg.Images.Presets, transformCache, purgers, dispatcher)
+		routes.BindAPIImages(server, service, transformCache, purgers, dispatcher)
+
+		if cfg.API.Presign.Enabled {
+			_, isPresigner := service.DefaultSource.(kritiimages.ImagePresigner)
+			if !isPresigner && cfg.API.Presign.Secret == "" {
+				panic("api.presign.secret is required for presigned uploads with the configured image source")
+			}
+			routes.BindAPIPresignedUpload(server, service, &cfg.API.Presign, transformCache, purgers, dispatcher)
+		}
+
+		if cfg.API.Resumable.Enabled {
+			routes.BindAPIResumableUpload(server, service, &cfg.API, transformCache, purgers, dispatcher)
+		}
 	}
 
 	// Register 404 handler last, after all other routes
@@ -44,12 +82,23 @@ func ConfigureAndGet(ctx context.Context, cfg *config.Config) (*fiber.App, *krit
 }
 
 func initFiberApp(cfg *config.Config) *fiber.App {
+	// request bodies are streamed so that uploads are not held in memory, body
+	// limit is not enforced by fiber while streaming so it is checked below
+	bodyLimit := maxBodySize(cfg)
 	server := fiber.New(fiber.Config{
 		AppName:               "Kriti Images",
 		EnablePrintRoutes:     cfg.Server.EnablePrintRoutes,
 		DisableStartupMessage: true,
 		ReadTimeout:           cfg.Server.ReadTimeout,
 		WriteTimeout:          cfg.Server.WriteTimeout,
+		BodyLimit:             bodyLimit,
+		StreamRequestBody:     true,
+	})
+	server.Use(func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() > bodyLimit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+		return c.Next()
 	})
 	server.Use(limiter.New(limiter.Config{
 		Max:               cfg.Server.Limiter.Max,
@@ -78,6 +127,132 @@ func initFiberApp(cfg *config.Config) *fiber.App {
 	return server
 }
 
+// maxBodySize returns the largest size of uploads in bytes, with room for
+// multipart encoding of the upload API.
+func maxBodySize(cfg *config.Config) int {
+	size := max(cfg.Images.MaxImageSizeInBytes, cfg.API.Presign.MaxFileSizeInBytes, cfg.API.Resumable.MaxFileSizeInBytes)
+	return int(size) + 1024*1024
+}
+
+// getTransformCache returns nil when cache is disabled
+func getTransformCache(cfg *config.ImagesConfigCache) *cache.Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	transformCache, err := cache.New(cfg.Dir, cfg.MaxSizeInBytes)
+	if err != nil {
+		panic(fmt.Sprintf("failed to configure cache; %s", err.Error()))
+	}
+	return transformCache
+}
+
+// getEventDispatcher returns nil when no webhooks are configured
+func getEventDispatcher(cfg *config.WebhooksConfig) *events.Dispatcher {
+	if len(cfg.Endpoints) == 0 {
+		return nil
+	}
+
+	webhooks := make([]events.Webhook, 0, len(cfg.Endpoints))
+	for _, endpoint := range cfg.Endpoints {
+		webhook := events.Webhook{URL: endpoint.URL, Secret: endpoint.Secret}
+		for _, t := range endpoint.Events {
+			webhook.Events = append(webhook.Events, events.Type(t))
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	dispatcher, err := events.NewDispatcher(webhooks, events.Options{
+		OutboxDir:   cfg.OutboxDir,
+		MaxAttempts: cfg.MaxAttempts,
+		Timeout:     cfg.Timeout,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to configure webhooks; %s", err.Error()))
+	}
+	return dispatcher
+}
+
+func getCDNPurgers(cfg *config.CDNConfig) cdn.Purgers {
+	purgers := make(cdn.Purgers, 0, len(cfg.Purgers))
+	for _, purger := range cfg.Purgers {
+		switch purger.Type {
+		case "http":
+			method := purger.Method
+			if method == "" {
+				method = "PURGE"
+			}
+			purgers = append(purgers, &cdn.HTTPPurger{Method: method, URL: purger.URL, Headers: purger.Headers})
+		case "fastly":
+			purgers = append(purgers, &cdn.FastlyPurger{ServiceID: purger.ServiceID, APIToken: purger.APIToken, SoftPurge: purger.SoftPurge})
+		case "cloudflare":
+			purgers = append(purgers, &cdn.CloudflarePurger{ZoneID: purger.ZoneID, APIToken: purger.APIToken})
+		case "varnish":
+			purgers = append(purgers, &cdn.VarnishPurger{URL: purger.URL})
+		default:
+			panic(fmt.Sprintf("unknown cdn purger type %s", purger.Type))
+		}
+	}
+	return purgers
+}
+
+// getFonts returns fonts of `text` transformation, panics if the default font
+// can not be loaded so that misconfiguration is caught at startup.
+func getFonts(cfg *config.ImagesConfigFonts) *fonts.Registry {
+	registry := fonts.New(cfg.Dir, cfg.Default)
+	if _, err := registry.Font(""); err != nil {
+		panic(fmt.Sprintf("invalid images.fonts; %s", err.Error()))
+	}
+	return registry
+}
+
+// getTemplates loads templates of template route, panics on invalid templates
+// so that misconfiguration is caught at startup.
+func getTemplates(cfg *config.ImagesConfigTemplates) map[string]*kritiimages.Template {
+	loaded, err := templates.Load(cfg.Dir)
+	if err != nil {
+		panic(fmt.Sprintf("invalid images.templates; %s", err.Error()))
+	}
+	return loaded
+}
+
+// getWatermarks validates configured watermarks, panics on invalid config so
+// that images are never served without them.
+func getWatermarks(cfg *config.ImagesConfig, sources map[string]kritiimages.ImageSource) map[string]kritiimages.Overlay {
+	watermarks := make(map[string]kritiimages.Overlay, len(cfg.Watermarks))
+	for name, watermark := range cfg.Watermarks {
+		if _, ok := sources[name]; !ok {
+			panic(fmt.Sprintf("watermark configured for unknown image source %s", name))
+		}
+		if watermark.Path == "" {
+			panic(fmt.Sprintf("images.watermarks.%s.path is required", name))
+		}
+		gravity, err := utils.ParseGravityValue(watermark.Gravity)
+		if err != nil {
+			panic(fmt.Sprintf("invalid images.watermarks.%s.gravity; %s", name, err.Error()))
+		}
+		if watermark.Opacity < 0 || watermark.Opacity > 100 || watermark.Scale < 0 || watermark.Scale > 1 {
+			panic(fmt.Sprintf("invalid images.watermarks.%s; opacity must be between 0 and 100 and scale between 0 and 1", name))
+		}
+
+		opacity := watermark.Opacity
+		if opacity == 0 { // invisible watermark is never intended
+			opacity = 100
+		}
+
+		watermarks[name] = kritiimages.Overlay{
+			Path:    watermark.Path,
+			Gravity: gravity,
+			X:       watermark.X,
+			Y:       watermark.Y,
+			Scale:   watermark.Scale,
+			Opacity: float64(opacity) / 100,
+			Tile:    watermark.Tile,
+		}
+	}
+	return watermarks
+}
+
 func getImageSources(ctx context.Context, cfg *config.ImagesConfig) map[string]kritiimages.ImageSource {
 	validations := imagesources.SourceImageValidations{
 		MaxImageDimension:  cfg.MaxImageDimension,
@@ -89,6 +264,18 @@ func getImageSources(ctx context.Context, cfg *config.ImagesConfig) map[string]k
 	case "awss3":
 		s3Client := getS3Client(ctx)
 		sources["awss3"] = kritiimages.NewImageSourceS3(ctx, cfg.AwsS3.Bucket, s3Client, &validations)
+	case "gcs":
+		gcsClient := getGCSClient(ctx, &cfg.GCS)
+		sources["gcs"] = kritiimages.NewImageSourceGCS(ctx, cfg.GCS.Bucket, gcsClient, &validations)
+	case "azureblob":
+		azureClient := getAzureBlobClient(&cfg.AzureBlob)
+		sources["azureblob"] = kritiimages.NewImageSourceAzureBlob(ctx, cfg.AzureBlob.Container, azureClient, &validations)
+	case "webfolder":
+		webFolder, err := kritiimages.NewImageSourceWebFolder(cfg.WebFolder.BaseURL, cfg.WebFolder.Headers, &validations)
+		if err != nil {
+			panic(fmt.Sprintf("failed to configure webfolder image source; %s", err.Error()))
+		}
+		sources["webfolder"] = webFolder
 	case "local":
 		sources["local"] = kritiimages.NewImageSourceLocal(cfg.Local.BasePath, &validations)
 	}
@@ -106,3 +293,102 @@ func getS3Client(ctx context.Context) *s3.Client {
 
 	return s3.NewFromConfig(cfg)
 }
+
+func getGCSClient(ctx context.Context, cfg *config.ImagesConfigGCS) *storage.Client {
+	opts := make([]option.ClientOption, 0)
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	if cfg.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.Endpoint))
+		if cfg.CredentialsFile == "" {
+			// emulators like fake-gcs-server do not need credentials
+			opts = append(opts, option.WithoutAuthentication())
+		}
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("failed to get gcs client instance; %s", err.Error()))
+	}
+
+	return client
+}
+
+func getAzureBlobClient(cfg *config.ImagesConfigAzureBlob) *azblob.Client {
+	var client *azblob.Client
+	var err error
+	if cfg.ConnectionString != "" {
+		client, err = azblob.NewClientFromConnectionString(cfg.ConnectionString, nil)
+	} else {
+		serviceURL := cfg.Endpoint
+		if cfg.SASToken != "" {
+			serviceURL = strings.TrimSuffix(serviceURL, "/") + "/?" + strings.TrimPrefix(cfg.SASToken, "?")
+		}
+		client, err = azblob.NewClientWithNoCredential(serviceURL, nil)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("failed to get azure blob client instance; %s", err.Error()))
+	}
+
+	return client
+}
+
+func getAuthMiddleware(cfg *config.APIConfigAuth) fiber.Handler {
+	authenticators := make([]auth.Authenticator, 0)
+
+	if len(cfg.APIKeys) > 0 {
+		keys := make([]auth.APIKey, 0, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			operations := make([]auth.Operation, 0, len(key.Operations))
+			for _, op := range key.Operations {
+				operations = append(operations, auth.Operation(op))
+			}
+			keys = append(keys, auth.APIKey{
+				Name:       key.Name,
+				KeySHA256:  key.KeySHA256,
+				Prefixes:   key.Prefixes,
+				Operations: operations,
+			})
+		}
+
+		apiKeyAuthenticator, err := auth.NewAPIKeyAuthenticator(keys)
+		if err != nil {
+			panic(fmt.Sprintf("failed to configure API key auth; %s", err.Error()))
+		}
+		authenticators = append(authenticators, apiKeyAuthenticator)
+	}
+
+	if cfg.JWT.HS256Secret != "" || cfg.JWT.RS256PublicKeyFile != "" {
+		opts := auth.JWTOptions{
+			HS256Secret: []byte(cfg.JWT.HS256Secret),
+			Issuer:      cfg.JWT.Issuer,
+			Audience:    cfg.JWT.Audience,
+		}
+		if cfg.JWT.RS256PublicKeyFile != "" {
+			publicKey, err := os.ReadFile(cfg.JWT.RS256PublicKeyFile)
+			if err != nil {
+				panic(fmt.Sprintf("failed to read JWT public key; %s", err.Error()))
+			}
+			opts.RS256PublicKey = publicKey
+		}
+
+		jwtAuthenticator, err := auth.NewJWTAuthenticator(opts)
+		if err != nil {
+			panic(fmt.Sprintf("failed to configure JWT auth; %s", err.Error()))
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	if len(authenticators) == 0 {
+		log.Warn("api auth is enabled but no API keys or JWT keys are configured, all API requests will be rejected")
+	}
+
+	return auth.New(auth.Config{
+		Authenticators: authenticators,
+		Next: func(c *fiber.Ctx) bool {
+			// direct uploads are authorized by signed one-time tokens
+			return strings.HasPrefix(c.Path(), routes.DirectUploadPath)
+		},
+	})
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// BindAPIImages binds APIs to manage images stored in the default source i.e.
+// list, read metadata and delete. Each API is available only when the source
+// implements the related capability, else `501 Not Implemented` is returned.
+// Variants of deleted images are removed from `transformCache`, when not nil,
+// and from CDNs using `purgers`.
+func BindAPIImages(server *fiber.App, k *kritiimages.KritiImages, transformCache *cache.Cache, purgers cdn.Purgers, dispatcher *events.Dispatcher) {
+	server.Get("/api/v0/images", func(c *fiber.Ctx) error {
+		lister, ok := k.DefaultSource.(kritiimages.ImageLister)
+		if !ok {
+			return c.Status(http.StatusNotImplemented).JSON(fiber.Map{
+				"error": "Listing images is not supported by the image source",
+			})
+		}
+
+		limit := defaultListLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxListLimit {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": "limit must be a number between 1 and 1000",
+				})
+			}
+			limit = parsed
+		}
+
+		if !auth.Authorize(c, auth.OperationRead, c.Query("prefix")) {
+			return auth.Forbidden(c, auth.OperationRead, c.Query("prefix"))
+		}
+
+		list, err := lister.ListImages(c.Context(), c.Query("prefix"), c.Query("cursor"), limit)
+		if err != nil {
+			log.Errorw("failed to list images", "prefix", c.Query("prefix"), "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to list images",
+			})
+		}
+
+		return c.Status(http.StatusOK).JSON(list)
+	})
+
+	// GET is also registered for HEAD, metadata is sent as headers too
+	server.Get("/api/v0/images/*", func(c *fiber.Ctx) error {
+		filename, err := url.PathUnescape(c.Params("*"))
+		if err != nil || filename == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid image path",
+			})
+		}
+
+		if !auth.Authorize(c, auth.OperationRead, filename) {
+			return auth.Forbidden(c, auth.OperationRead, filename)
+		}
+
+		stater, ok := k.DefaultSource.(kritiimages.ImageStater)
+		if !ok {
+			return c.Status(http.StatusNotImplemented).JSON(fiber.Map{
+				"error": "Reading image metadata is not supported by the image source",
+			})
+		}
+
+		stat, err := stater.StatImage(c.Context(), filename)
+		if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		} else if err != nil {
+			log.Errorw("failed to read image metadata", "filename", filename, "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read image metadata",
+			})
+		}
+
+		c.Set("Last-Modified", stat.ModTime.Format(http.TimeFormat))
+		c.Set("X-Image-Size", strconv.FormatInt(stat.Size, 10))
+		c.Set("X-Image-Width", strconv.Itoa(stat.Width))
+		c.Set("X-Image-Height", strconv.Itoa(stat.Height))
+		c.Set("X-Image-Format", stat.Format)
+		c.Set("X-Image-Content-Hash", stat.ContentHash)
+
+		return c.Status(http.StatusOK).JSON(stat)
+	})
+
+	server.Delete("/api/v0/images/*", func(c *fiber.Ctx) error {
+		filename, err := url.PathUnescape(c.Params("*"))
+		if err != nil || filename == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid image path",
+			})
+		}
+
+		if !auth.Authorize(c, auth.OperationDelete, filename) {
+			return auth.Forbidden(c, auth.OperationDelete, filename)
+		}
+
+		deleter, ok := k.DefaultSource.(kritiimages.ImageDeleter)
+		if !ok {
+			return c.Status(http.StatusNotImplemented).JSON(fiber.Map{
+				"error": "Deleting images is not supported by the image source",
+			})
+		}
+
+		err = deleter.DeleteImage(c.Context(), filename)
+		if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		} else if err != nil {
+			log.Errorw("failed to delete image", "filename", filename, "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete image",
+			})
+		}
+
+		invalidateVariants(filename, transformCache, purgers)
+
+		log.Infow("image deleted successfully", "filename", filename)
+		dispatcher.Emit(events.ImageDeleted, map[string]any{"filename": filename})
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"message":  "Image deleted successfully",
+			"filename": filename,
+		})
+	})
+}
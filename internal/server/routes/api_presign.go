@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+// DirectUploadPath is the path prefix of uploads authorized by signed one-time
+// tokens, requests to it must skip the auth middleware.
+const DirectUploadPath = "/api/v0/uploads/direct/"
+
+type presignRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type completeRequest struct {
+	Filename string `json:"filename"`
+}
+
+// BindAPIPresignedUpload binds APIs for uploads which do not pass image bytes
+// through the server. Client requests an upload, uploads the image directly
+// to the storage and then calls complete API to validate the stored image.
+//
+// Sources implementing ImagePresigner (e.g. AWS S3) get presigned URLs, others
+// get a signed one-time token to upload using DirectUploadPath, which is bound
+// only for such sources.
+func BindAPIPresignedUpload(server *fiber.App, k *kritiimages.KritiImages, cfg *config.APIConfigPresign, transformCache *cache.Cache, purgers cdn.Purgers, dispatcher *events.Dispatcher) {
+	usedTokens, err := auth.NewUsedUploadTokens(cfg.UsedTokensDir)
+	if err != nil {
+		panic(fmt.Sprintf("failed to configure presigned uploads; %s", err.Error()))
+	}
+
+	server.Post("/api/v0/uploads/presign", func(c *fiber.Ctx) error {
+		var req presignRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		if err := validatePresignRequest(&req, cfg); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if !auth.Authorize(c, auth.OperationUpload, req.Filename) {
+			return auth.Forbidden(c, auth.OperationUpload, req.Filename)
+		}
+
+		var upload *kritiimages.PresignedUpload
+		if presigner, ok := k.DefaultSource.(kritiimages.ImagePresigner); ok {
+			var err error
+			upload, err = presigner.PresignUpload(c.Context(), req.Filename, req.ContentType, req.Size, cfg.Expiration)
+			if err != nil {
+				log.Errorw("failed to presign upload", "filename", req.Filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to create upload",
+				})
+			}
+		} else {
+			token, err := auth.NewUploadToken(req.Filename, req.ContentType, req.Size, cfg.Expiration)
+			if err != nil {
+				log.Errorw("failed to create upload token", "filename", req.Filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to create upload",
+				})
+			}
+			signed, err := token.Sign([]byte(cfg.Secret))
+			if err != nil {
+				log.Errorw("failed to sign upload token", "filename", req.Filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to create upload",
+				})
+			}
+
+			upload = &kritiimages.PresignedUpload{
+				URL:       c.BaseURL() + DirectUploadPath + signed,
+				Method:    http.MethodPut,
+				Headers:   map[string]string{fiber.HeaderContentType: req.ContentType},
+				ExpiresAt: token.ExpiresAt,
+			}
+		}
+
+		log.Infow("upload presigned", "filename", req.Filename, "size", req.Size)
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"filename":     req.Filename,
+			"upload":       upload,
+			"complete_url": c.BaseURL() + "/api/v0/uploads/complete",
+		})
+	})
+
+	// presigners get presigned URLs instead, tokens must never be accepted for them
+	if _, isPresigner := k.DefaultSource.(kritiimages.ImagePresigner); !isPresigner {
+		server.Put(DirectUploadPath+":token", func(c *fiber.Ctx) error {
+			token, err := auth.VerifyUploadToken([]byte(cfg.Secret), c.Params("token"))
+			if err != nil {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			// restrictions of presign requests are applied again, e.g. in case prefix changed
+			// since the token was signed
+			if err := validatePresignRequest(&presignRequest{Filename: token.Filename, ContentType: token.ContentType, Size: token.MaxSize}, cfg); err != nil {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			if c.Get(fiber.HeaderContentType) != token.ContentType {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Content-Type must be %s", token.ContentType),
+				})
+			}
+
+			// body is streamed to the source, its size must be known upfront
+			size := int64(c.Request().Header.ContentLength())
+			if size < 0 {
+				return c.Status(http.StatusLengthRequired).JSON(fiber.Map{
+					"error": "Content-Length header is required",
+				})
+			} else if size > token.MaxSize {
+				return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{
+					"error": fmt.Sprintf("Image must not be larger than %d bytes", token.MaxSize),
+				})
+			}
+
+			// token is used before storing so that concurrent uploads with the same
+			// token are rejected, it is released if storing fails
+			if err := usedTokens.Use(token); err != nil {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			if err := k.DefaultSource.PutImage(c.Context(), token.Filename, io.LimitReader(requestBody(c), size), size); isInvalidImage(err) {
+				usedTokens.Release(token)
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid image: %s", err.Error()),
+				})
+			} else if err != nil {
+				usedTokens.Release(token)
+				log.Errorw("failed to store direct upload", "filename", token.Filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to save image",
+				})
+			}
+
+			invalidateVariants(token.Filename, transformCache, purgers)
+			return c.SendStatus(http.StatusOK)
+		})
+	}
+
+	server.Post("/api/v0/uploads/complete", func(c *fiber.Ctx) error {
+		var req completeRequest
+		if err := c.BodyParser(&req); err != nil || req.Filename == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "filename is required",
+			})
+		}
+
+		if !strings.HasPrefix(req.Filename, cfg.Prefix) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("filename must start with %s", cfg.Prefix),
+			})
+		}
+
+		if !auth.Authorize(c, auth.OperationUpload, req.Filename) {
+			return auth.Forbidden(c, auth.OperationUpload, req.Filename)
+		}
+
+		var version *kritiimages.ImageVersion
+		if versioner, ok := k.DefaultSource.(kritiimages.ImageVersioner); ok {
+			var err error
+			version, err = versioner.VersionImage(c.Context(), req.Filename)
+			if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+				return c.Status(http.StatusNotFound).JSON(fiber.Map{
+					"error": "Image not found, upload it before completing",
+				})
+			} else if err != nil {
+				log.Errorw("failed to get uploaded image version", "filename", req.Filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to validate image",
+				})
+			}
+		}
+
+		// GetImage applies the source image validations i.e. max file size & dimensions
+		img, format, err := k.DefaultSource.GetImage(c.Context(), req.Filename)
+		if err == nil && format != formatFromFilename(req.Filename) {
+			err = fmt.Errorf("%w: content (%s) does not match file extension", kritiimages.ErrInvalidSourceImage, format)
+		}
+		if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found, upload it before completing",
+			})
+		} else if isInvalidImage(err) {
+			log.Warnw("uploaded image failed validation", "filename", req.Filename, "error", err.Error())
+			// only images stored while upload URLs are valid are deleted, older images
+			// are kept e.g. if they are invalid as per changed limits
+			if deleter, ok := k.DefaultSource.(kritiimages.ImageDeleter); ok && version != nil && time.Since(version.ModTime) <= cfg.Expiration {
+				if err := deleter.DeleteImage(c.Context(), req.Filename); err != nil {
+					log.Errorw("failed to delete invalid upload", "filename", req.Filename, "error", err.Error())
+				}
+				invalidateVariants(req.Filename, transformCache, purgers)
+			}
+			return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid image: %s", err.Error()),
+			})
+		} else if err != nil {
+			log.Errorw("failed to validate uploaded image", "filename", req.Filename, "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to validate image",
+			})
+		}
+
+		log.Infow("image uploaded successfully", "filename", req.Filename, "format", format, "size", fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()))
+		dispatcher.Emit(events.ImageUploaded, imageEventData(req.Filename, format, &image.Config{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}))
+
+		// image is written directly to the storage e.g. with presigned URL
+		invalidateVariants(req.Filename, transformCache, purgers)
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"message":  "Image uploaded successfully",
+			"filename": req.Filename,
+			"format":   format,
+			"size": fiber.Map{
+				"width":  img.Bounds().Dx(),
+				"height": img.Bounds().Dy(),
+			},
+		})
+	})
+}
+
+// validatePresignRequest returns meaningful errors, they are sent as response as is
+func validatePresignRequest(req *presignRequest, cfg *config.APIConfigPresign) error {
+	if req.Filename == "" {
+		return fmt.Errorf("filename is required")
+	}
+	if strings.Contains(req.Filename, "..") || strings.HasPrefix(req.Filename, "/") {
+		return fmt.Errorf("invalid filename")
+	}
+	if !strings.HasPrefix(req.Filename, cfg.Prefix) {
+		return fmt.Errorf("filename must start with %s", cfg.Prefix)
+	}
+
+	format := formatFromFilename(req.Filename)
+	if format == "" {
+		return fmt.Errorf("unsupported file format, only JPG, PNG and WebP are allowed")
+	}
+	if req.ContentType != "image/"+format {
+		return fmt.Errorf("content_type must be image/%s for %s", format, filepath.Ext(req.Filename))
+	}
+
+	if req.Size <= 0 || req.Size > cfg.MaxFileSizeInBytes {
+		return fmt.Errorf("size must be between 1 and %d bytes", cfg.MaxFileSizeInBytes)
+	}
+
+	return nil
+}
+
+// formatFromFilename returns image format as returned by image.Decode for
+// extension of `filename`, empty if not supported
+func formatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".webp":
+		return "webp"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,248 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+// presignerSource is a local source which presigns uploads, like S3
+type presignerSource struct {
+	*imagesources.ImageSourceLocal
+}
+
+func (s *presignerSource) PresignUpload(ctx context.Context, fileName, contentType string, size int64, expires time.Duration) (*kritiimages.PresignedUpload, error) {
+	return &kritiimages.PresignedUpload{URL: "https://storage.example/" + fileName, Method: http.MethodPut}, nil
+}
+
+// failingSource is a local source which fails to read images, e.g. on network errors
+type failingSource struct {
+	*imagesources.ImageSourceLocal
+}
+
+func (s *failingSource) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	return nil, "", errors.New("connection reset by peer")
+}
+
+func newTestPresignApp(t *testing.T, presigner bool) (*fiber.App, *config.APIConfigPresign, string) {
+	t.Helper()
+	return newTestPresignAppWith(t, func(local *imagesources.ImageSourceLocal) kritiimages.ImageSource {
+		if presigner {
+			return &presignerSource{local}
+		}
+		return local
+	})
+}
+
+func newTestPresignAppWith(t *testing.T, wrap func(*imagesources.ImageSourceLocal) kritiimages.ImageSource) (*fiber.App, *config.APIConfigPresign, string) {
+	t.Helper()
+	basePath := t.TempDir()
+	source := wrap(kritiimages.NewImageSourceLocal(basePath, &imagesources.SourceImageValidations{MaxImageDimension: 100, MaxFileSizeInBytes: 1024 * 1024}))
+	cfg := &config.APIConfigPresign{Enabled: true, Secret: "secret", Prefix: "uploads/", MaxFileSizeInBytes: 1024 * 1024, Expiration: time.Minute, UsedTokensDir: t.TempDir()}
+
+	app := fiber.New()
+	BindAPIPresignedUpload(app, kritiimages.New(map[string]kritiimages.ImageSource{"local": source}, source), cfg, nil, nil, nil)
+	return app, cfg, basePath
+}
+
+func testPNG(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func signedUploadToken(t *testing.T, filename string, secret []byte) string {
+	t.Helper()
+	token, err := auth.NewUploadToken(filename, "image/png", 1024*1024, time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(secret) == 0 {
+		// forged as anyone can, when secret is not configured
+		payload, _ := json.Marshal(token)
+		encoded := base64.RawURLEncoding.EncodeToString(payload)
+		mac := hmac.New(sha256.New, nil)
+		mac.Write([]byte(encoded))
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	signed, err := token.Sign(secret)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return signed
+}
+
+func putDirectUpload(t *testing.T, app *fiber.App, url string, data []byte) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+	req.Header.Set(fiber.HeaderContentType, "image/png")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestDirectUpload(t *testing.T) {
+	app, _, basePath := newTestPresignApp(t, false)
+	data := testPNG(t, 4)
+
+	// presign, upload with the token once
+	body := strings.NewReader(`{"filename": "uploads/a.png", "content_type": "image/png", "size": 1024}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/uploads/presign", body)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected presign to succeed, got %v %v", resp, err)
+	}
+	var presigned struct {
+		Upload kritiimages.PresignedUpload `json:"upload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&presigned); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	url := presigned.Upload.URL[strings.Index(presigned.Upload.URL, DirectUploadPath):]
+
+	if status := putDirectUpload(t, app, url, []byte("not an image")); status != http.StatusBadRequest {
+		t.Errorf("Expected invalid image to be rejected, got %d", status)
+	}
+	// token is released as nothing was stored
+	if status := putDirectUpload(t, app, url, data); status != http.StatusOK {
+		t.Errorf("Expected upload to succeed, got %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(basePath, "uploads", "a.png")); err != nil {
+		t.Errorf("Expected image to be stored, got %v", err)
+	}
+	if status := putDirectUpload(t, app, url, data); status != http.StatusForbidden {
+		t.Errorf("Expected reused token to be forbidden, got %d", status)
+	}
+}
+
+func TestDirectUploadRejectsTokens(t *testing.T) {
+	secret := []byte("secret")
+
+	tests := []struct {
+		name      string
+		presigner bool
+		cfgSecret string
+		filename  string
+		secret    []byte
+		expected  int
+	}{
+		{name: "forged with empty secret", cfgSecret: "", filename: "uploads/a.png", expected: http.StatusForbidden},
+		{name: "signed with other secret", cfgSecret: "secret", filename: "uploads/a.png", secret: []byte("other"), expected: http.StatusForbidden},
+		{name: "outside prefix", cfgSecret: "secret", filename: "products/a.png", secret: secret, expected: http.StatusForbidden},
+		{name: "traversal", cfgSecret: "secret", filename: "uploads/../a.png", secret: secret, expected: http.StatusForbidden},
+		{name: "presigner source", presigner: true, cfgSecret: "secret", filename: "uploads/a.png", secret: secret, expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, cfg, basePath := newTestPresignApp(t, tt.presigner)
+			cfg.Secret = tt.cfgSecret
+
+			url := DirectUploadPath + signedUploadToken(t, tt.filename, tt.secret)
+			if status := putDirectUpload(t, app, url, testPNG(t, 4)); status != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, status)
+			}
+
+			stored, _ := filepath.Glob(filepath.Join(basePath, "*.png"))
+			nested, _ := filepath.Glob(filepath.Join(basePath, "*", "*.png"))
+			if stored = append(stored, nested...); len(stored) != 0 {
+				t.Errorf("Expected nothing to be stored, got %v", stored)
+			}
+		})
+	}
+}
+
+func TestCompleteUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		failing  bool
+		filename string
+		data     []byte
+		age      time.Duration // since the image was stored
+		expected int
+		kept     bool
+	}{
+		{name: "valid", filename: "uploads/a.png", data: testPNG(t, 4), expected: http.StatusOK, kept: true},
+		{name: "missing", filename: "uploads/a.png", expected: http.StatusNotFound},
+		{name: "invalid upload", filename: "uploads/a.jpg", data: testPNG(t, 4), expected: http.StatusUnprocessableEntity},
+		{name: "invalid old image", filename: "uploads/a.png", data: testPNG(t, 200), age: time.Hour, expected: http.StatusUnprocessableEntity, kept: true},
+		{name: "source error", failing: true, filename: "uploads/a.png", data: testPNG(t, 4), expected: http.StatusInternalServerError, kept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _, basePath := newTestPresignAppWith(t, func(local *imagesources.ImageSourceLocal) kritiimages.ImageSource {
+				if tt.failing {
+					return &failingSource{local}
+				}
+				return local
+			})
+
+			path := filepath.Join(basePath, filepath.FromSlash(tt.filename))
+			if tt.data != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if err := os.WriteFile(path, tt.data, 0644); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				modTime := time.Now().Add(-tt.age)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/uploads/complete", strings.NewReader(`{"filename": "`+tt.filename+`"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+
+			if _, err := os.Stat(path); (err == nil) != tt.kept {
+				t.Errorf("Expected image kept=%v, got stat error %v", tt.kept, err)
+			}
+		})
+	}
+}
+
+func TestDirectUploadSourceError(t *testing.T) {
+	app, cfg, basePath := newTestPresignApp(t, false)
+	// directory in place of the image, source fails to store it
+	if err := os.MkdirAll(filepath.Join(basePath, "uploads", "a.png"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	url := DirectUploadPath + signedUploadToken(t, "uploads/a.png", []byte(cfg.Secret))
+	if status := putDirectUpload(t, app, url, testPNG(t, 4)); status != http.StatusInternalServerError {
+		t.Errorf("Expected %d, got %d", http.StatusInternalServerError, status)
+	}
+}
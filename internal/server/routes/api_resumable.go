@@ -0,0 +1,262 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/internal/tus"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusChunkType  = "application/offset+octet-stream"
+)
+
+// BindAPIResumableUpload binds APIs for resumable uploads as per tus protocol
+// (https://tus.io/protocols/resumable-upload) on /api/v0/uploads.
+// Chunks are staged on local disk and the image is stored in the default source
+// once all bytes are received.
+//
+// `filename` metadata is required while creating an upload.
+func BindAPIResumableUpload(server *fiber.App, k *kritiimages.KritiImages, cfg *config.APIConfig, transformCache *cache.Cache, purgers cdn.Purgers, dispatcher *events.Dispatcher) {
+	store, err := tus.NewStore(cfg.Resumable.StagingDir, cfg.Resumable.Expiration)
+	if err != nil {
+		panic(fmt.Sprintf("failed to configure resumable uploads; %s", err.Error()))
+	}
+	go removeExpiredUploads(store)
+
+	server.Options("/api/v0/uploads", func(c *fiber.Ctx) error {
+		c.Set("Tus-Resumable", tusVersion)
+		c.Set("Tus-Version", tusVersion)
+		c.Set("Tus-Extension", tusExtensions)
+		c.Set("Tus-Max-Size", strconv.FormatInt(cfg.Resumable.MaxFileSizeInBytes, 10))
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	server.Post("/api/v0/uploads", requireTusVersion, func(c *fiber.Ctx) error {
+		length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 1 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Upload-Length header is required",
+			})
+		}
+		if length > cfg.Resumable.MaxFileSizeInBytes {
+			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": fmt.Sprintf("Image must not be larger than %d bytes", cfg.Resumable.MaxFileSizeInBytes),
+			})
+		}
+
+		metadata, err := tus.ParseMetadata(c.Get("Upload-Metadata"))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		filename := metadata["filename"]
+		if filename == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "filename is required in Upload-Metadata",
+			})
+		}
+		if formatFromFilename(filename) == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported file format. Only JPG, PNG, and WebP are allowed",
+			})
+		}
+
+		if !auth.Authorize(c, auth.OperationUpload, filename) {
+			return auth.Forbidden(c, auth.OperationUpload, filename)
+		}
+
+		upload, err := store.Create(length, metadata)
+		if err != nil {
+			log.Errorw("failed to create resumable upload", "filename", filename, "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create upload",
+			})
+		}
+
+		log.Infow("resumable upload created", "id", upload.ID, "filename", filename, "length", length)
+
+		c.Set("Location", c.BaseURL()+"/api/v0/uploads/"+upload.ID)
+		c.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+		return c.SendStatus(http.StatusCreated)
+	})
+
+	server.Head("/api/v0/uploads/:id", func(c *fiber.Ctx) error {
+		c.Set("Tus-Resumable", tusVersion)
+		c.Set("Cache-Control", "no-store")
+
+		upload, err := getUpload(c, store)
+		if upload == nil {
+			return err
+		}
+
+		c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		c.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+		return c.SendStatus(http.StatusOK)
+	})
+
+	server.Patch("/api/v0/uploads/:id", requireTusVersion, func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderContentType) != tusChunkType {
+			return c.Status(http.StatusUnsupportedMediaType).JSON(fiber.Map{
+				"error": "Content-Type must be " + tusChunkType,
+			})
+		}
+
+		offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Upload-Offset header is required",
+			})
+		}
+
+		upload, err := getUpload(c, store)
+		if upload == nil {
+			return err
+		}
+
+		// an empty chunk for a complete upload retries storing the image; chunk
+		// is streamed to the staging file, bytes received before the connection
+		// drops are kept
+		if !upload.IsComplete() || c.Request().Header.ContentLength() != 0 {
+			upload, err = store.WriteChunk(upload.ID, offset, requestBody(c))
+			if errors.Is(err, tus.ErrOffsetMismatch) {
+				return c.Status(http.StatusConflict).JSON(fiber.Map{
+					"error": fmt.Sprintf("Upload-Offset must be %d", upload.Offset),
+				})
+			} else if errors.Is(err, tus.ErrSizeExceeded) {
+				return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{
+					"error": "Chunk exceeds Upload-Length",
+				})
+			} else if err != nil {
+				log.Errorw("failed to write upload chunk", "id", c.Params("id"), "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to write chunk",
+				})
+			}
+		}
+
+		c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		c.Set("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+		if !upload.IsComplete() {
+			return c.SendStatus(http.StatusNoContent)
+		}
+
+		return finalizeUpload(c, k, store, transformCache, purgers, dispatcher, upload, cfg.Upload.Normalize)
+	})
+
+	server.Delete("/api/v0/uploads/:id", requireTusVersion, func(c *fiber.Ctx) error {
+		upload, err := getUpload(c, store)
+		if upload == nil {
+			return err
+		}
+
+		if err := store.Remove(upload.ID); err != nil {
+			log.Errorw("failed to terminate upload", "id", upload.ID, "error", err.Error())
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to terminate upload",
+			})
+		}
+		return c.SendStatus(http.StatusNoContent)
+	})
+}
+
+// finalizeUpload stores the completely received upload in the default source
+// and sends the response.
+// Invalid images are discarded, on other errors staged data is kept so that
+// client can retry by sending an empty chunk.
+func finalizeUpload(c *fiber.Ctx, k *kritiimages.KritiImages, store *tus.Store, transformCache *cache.Cache, purgers cdn.Purgers, dispatcher *events.Dispatcher, upload *tus.Upload, normalize bool) error {
+	filename := upload.Metadata["filename"]
+
+	file, err := store.Open(upload.ID)
+	if err != nil {
+		log.Errorw("failed to open staged upload", "id", upload.ID, "error", err.Error())
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save image",
+		})
+	}
+	defer file.Close()
+
+	imgConfig, format, err := storeImage(c.Context(), k.DefaultSource, file, upload.Length, filename, normalize)
+	if errors.Is(err, errInvalidUpload) {
+		if err := store.Remove(upload.ID); err != nil {
+			log.Errorw("failed to remove invalid upload", "id", upload.ID, "error", err.Error())
+		}
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid image file",
+		})
+	} else if err != nil {
+		log.Errorw("failed to upload image", "id", upload.ID, "filename", filename, "error", err.Error())
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to save image: %s", err.Error()),
+		})
+	}
+
+	if err := store.Remove(upload.ID); err != nil {
+		log.Errorw("failed to remove completed upload", "id", upload.ID, "error", err.Error())
+	}
+
+	log.Infow("image uploaded successfully", "id", upload.ID, "filename", filename, "format", format, "size", fmt.Sprintf("%dx%d", imgConfig.Width, imgConfig.Height))
+	dispatcher.Emit(events.ImageUploaded, imageEventData(filename, format, imgConfig))
+	invalidateVariants(filename, transformCache, purgers)
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// getUpload returns the upload for `:id` param, if the upload is not found
+// or caller is not allowed to access it response is sent and nil upload is returned.
+func getUpload(c *fiber.Ctx, store *tus.Store) (*tus.Upload, error) {
+	upload, err := store.Get(c.Params("id"))
+	if errors.Is(err, tus.ErrUploadNotFound) {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found",
+		})
+	} else if err != nil {
+		log.Errorw("failed to get upload", "id", c.Params("id"), "error", err.Error())
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get upload",
+		})
+	}
+
+	if filename := upload.Metadata["filename"]; !auth.Authorize(c, auth.OperationUpload, filename) {
+		return nil, auth.Forbidden(c, auth.OperationUpload, filename)
+	}
+	return upload, nil
+}
+
+// requireTusVersion is a handler rejecting requests for unsupported protocol versions
+func requireTusVersion(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return c.Status(http.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": "Tus-Resumable header must be " + tusVersion,
+		})
+	}
+	return c.Next()
+}
+
+func removeExpiredUploads(store *tus.Store) {
+	for range time.Tick(time.Hour) {
+		removed, err := store.RemoveExpired()
+		if err != nil {
+			log.Errorw("failed to remove expired uploads", "error", err.Error())
+		} else if removed > 0 {
+			log.Infow("removed expired uploads", "count", removed)
+		}
+	}
+}
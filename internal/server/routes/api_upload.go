@@ -1,20 +1,47 @@
 package routes
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/auth"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/internal/imagesources"
 	"github.com/kritihq/kriti-images/pkg/kritiimages"
 )
 
-func BindAPIUpload(server *fiber.App, k *kritiimages.KritiImages) {
+// errInvalidUpload is returned when uploaded file is not a valid image
+var errInvalidUpload = errors.New("invalid image file")
+
+// BindAPIUpload binds APIs to upload new and update existing images. When
+// `api.upload.derivatives` are configured they are generated in background
+// after upload, `transformCache` is required for them. On update, stale variants
+// are removed from `transformCache` and CDNs using `purgers`.
+// Events are emitted using `dispatcher`, when not nil.
+func BindAPIUpload(server *fiber.App, k *kritiimages.KritiImages, cfg *config.APIConfig, presets map[string]string, transformCache *cache.Cache, purgers cdn.Purgers, dispatcher *events.Dispatcher) {
 	// NOTE: uploads only happen on default sources, for now
 
+	var derivatives *derivativeQueue
+	if len(cfg.Upload.Derivatives) > 0 {
+		derivatives = newDerivativeQueue(k, transformCache, dispatcher, presets, cfg.Upload.Derivatives, cfg.Upload.DerivativeWorkers)
+	}
+
 	server.Post("/api/v0/images", func(c *fiber.Ctx) error {
 		// Get the uploaded file
 		file, err := c.FormFile("image")
@@ -35,56 +62,124 @@ func BindAPIUpload(server *fiber.App, k *kritiimages.KritiImages) {
 
 		// Get filename from form or use original filename
 		filename := c.FormValue("filename")
-		if filename == "" {
+		contentAddressed := cfg.Upload.ContentAddressed || c.FormValue("content_addressed") == "true"
+		if !contentAddressed && filename == "" {
 			filename = file.Filename
 		}
 
-		// Validate filename extension
+		// Validate filename extension, content addressed names get extension of the content
 		ext := strings.ToLower(filepath.Ext(filename))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".webp" {
+		if !contentAddressed && ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".webp" {
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 				"error": "Unsupported file format. Only JPG, PNG, and WebP are allowed",
 			})
 		}
 
-		// Open the uploaded file
 		src, err := file.Open()
 		if err != nil {
 			log.Errorw("failed to open uploaded file", "error", err.Error())
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to process uploaded file",
+				"error": "Failed to save image",
 			})
 		}
 		defer src.Close()
 
-		// Decode the image
-		img, format, err := image.Decode(src)
-		if err != nil {
+		// normalized image is encoded once, so that content addressed name is
+		// derived from the bytes which are stored
+		content, size := io.ReadSeeker(src), file.Size
+		if cfg.Upload.Normalize {
+			target := filename
+			if contentAddressed {
+				target = "" // keeps format of the upload
+			}
+			encoded, err := normalizeImage(src, target)
+			if err != nil {
+				log.Errorw("failed to decode image", "error", err.Error())
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid image file",
+				})
+			}
+			content, size = bytes.NewReader(encoded), int64(len(encoded))
+		}
+
+		if contentAddressed {
+			filename, err = contentAddressedName(content, c.FormValue("prefix"))
+			if errors.Is(err, errInvalidUpload) {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			} else if err != nil {
+				log.Errorw("failed to hash uploaded file", "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to save image",
+				})
+			}
+		}
+
+		if !auth.Authorize(c, auth.OperationUpload, filename) {
+			return auth.Forbidden(c, auth.OperationUpload, filename)
+		}
+
+		// identical content is stored with the same name, re-uploading it is a no-op
+		if contentAddressed {
+			stat, err := statImage(c.Context(), k.DefaultSource, filename)
+			if err == nil {
+				log.Infow("image already exists", "filename", filename)
+
+				response := fiber.Map{
+					"message":  "Image already exists",
+					"filename": filename,
+					"format":   stat.Format,
+					"size": fiber.Map{
+						"width":  stat.Width,
+						"height": stat.Height,
+					},
+				}
+				if derivatives != nil {
+					response["variants"] = derivatives.Enqueue(c.BaseURL(), filename)
+				}
+				return c.Status(http.StatusOK).JSON(response)
+			} else if !errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+				log.Errorw("failed to check existing image", "filename", filename, "error", err.Error())
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to save image",
+				})
+			}
+		}
+
+		// Upload the image using the image source, content is normalized already
+		imgConfig, format, err := storeImage(c.Context(), k.DefaultSource, content, size, filename, false)
+		if errors.Is(err, errInvalidUpload) {
 			log.Errorw("failed to decode image", "error", err.Error())
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid image file",
 			})
-		}
-
-		// Upload the image using the image source
-		if err := k.DefaultSource.UploadImage(c.Context(), filename, img); err != nil {
+		} else if err != nil {
 			log.Errorw("failed to upload image", "filename", filename, "error", err.Error())
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 				"error": fmt.Sprintf("Failed to save image: %s", err.Error()),
 			})
 		}
 
-		log.Infow("image uploaded successfully", "filename", filename, "format", format, "size", fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()))
+		log.Infow("image uploaded successfully", "filename", filename, "format", format, "size", fmt.Sprintf("%dx%d", imgConfig.Width, imgConfig.Height))
+		dispatcher.Emit(events.ImageUploaded, imageEventData(filename, format, imgConfig))
+
+		// upload may overwrite an image with the same name
+		invalidateVariants(filename, transformCache, purgers)
 
-		return c.Status(http.StatusCreated).JSON(fiber.Map{
+		response := fiber.Map{
 			"message":  "Image uploaded successfully",
 			"filename": filename,
 			"format":   format,
 			"size": fiber.Map{
-				"width":  img.Bounds().Dx(),
-				"height": img.Bounds().Dy(),
+				"width":  imgConfig.Width,
+				"height": imgConfig.Height,
 			},
-		})
+		}
+		if derivatives != nil {
+			response["variants"] = derivatives.Enqueue(c.BaseURL(), filename)
+		}
+		return c.Status(http.StatusCreated).JSON(response)
 	})
 
 	server.Put("/api/v0/images", func(c *fiber.Ctx) error {
@@ -119,51 +214,225 @@ func BindAPIUpload(server *fiber.App, k *kritiimages.KritiImages) {
 			})
 		}
 
-		// Check if the image exists (for PUT, we might want to verify it exists)
-		_, _, err = k.DefaultSource.GetImage(c.Context(), filename)
-		if err != nil {
+		if !auth.Authorize(c, auth.OperationUpdate, filename) {
+			return auth.Forbidden(c, auth.OperationUpdate, filename)
+		}
+
+		// Check if the image exists, without decoding it. Existing images failing
+		// validations e.g. as per changed limits can be replaced
+		if _, err := statImage(c.Context(), k.DefaultSource, filename); errors.Is(err, kritiimages.ErrSourceImageNotFound) {
 			return c.Status(http.StatusNotFound).JSON(fiber.Map{
 				"error": "Image not found",
 			})
-		}
-
-		// Open the uploaded file
-		src, err := file.Open()
-		if err != nil {
-			log.Errorw("failed to open uploaded file", "error", err.Error())
+		} else if err != nil && !isInvalidImage(err) {
+			log.Errorw("failed to check existing image", "filename", filename, "error", err.Error())
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to process uploaded file",
+				"error": "Failed to update image",
 			})
 		}
-		defer src.Close()
 
-		// Decode the image
-		img, format, err := image.Decode(src)
-		if err != nil {
+		// Upload the image using the image source (this will overwrite the existing file)
+		imgConfig, format, err := saveImage(c.Context(), k.DefaultSource, file, filename, cfg.Upload.Normalize)
+		if errors.Is(err, errInvalidUpload) {
 			log.Errorw("failed to decode image", "error", err.Error())
 			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid image file",
 			})
-		}
-
-		// Upload the image using the image source (this will overwrite the existing file)
-		if err := k.DefaultSource.UploadImage(c.Context(), filename, img); err != nil {
+		} else if err != nil {
 			log.Errorw("failed to update image", "filename", filename, "error", err.Error())
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 				"error": fmt.Sprintf("Failed to update image: %s", err.Error()),
 			})
 		}
 
-		log.Infow("image updated successfully", "filename", filename, "format", format, "size", fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()))
+		log.Infow("image updated successfully", "filename", filename, "format", format, "size", fmt.Sprintf("%dx%d", imgConfig.Width, imgConfig.Height))
+		dispatcher.Emit(events.ImageUpdated, imageEventData(filename, format, imgConfig))
+
+		// transformed variants of the previous image are stale now
+		invalidateVariants(filename, transformCache, purgers)
 
-		return c.Status(http.StatusOK).JSON(fiber.Map{
+		response := fiber.Map{
 			"message":  "Image updated successfully",
 			"filename": filename,
 			"format":   format,
 			"size": fiber.Map{
-				"width":  img.Bounds().Dx(),
-				"height": img.Bounds().Dy(),
+				"width":  imgConfig.Width,
+				"height": imgConfig.Height,
 			},
-		})
+		}
+		if derivatives != nil {
+			response["variants"] = derivatives.Enqueue(c.BaseURL(), filename)
+		}
+		return c.Status(http.StatusOK).JSON(response)
 	})
 }
+
+// invalidateVariants removes transformed variants of `filename` from
+// `transformCache`, when not nil, and purges them from CDNs in background.
+// It must be called whenever the image is written or deleted in the source.
+func invalidateVariants(filename string, transformCache *cache.Cache, purgers cdn.Purgers) {
+	filename = kritiimages.CleanPath(filename)
+	if transformCache != nil {
+		if err := transformCache.Remove(filename); err != nil {
+			log.Errorw("failed to remove cached variants", "filename", filename, "error", err.Error())
+		}
+	}
+
+	if len(purgers) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := purgers.Purge(ctx, filename); err != nil {
+			log.Errorw("failed to purge variants from CDN", "filename", filename, "error", err.Error())
+			return
+		}
+		log.Infow("variants purged from CDN", "filename", filename, "key", cdn.SurrogateKey(filename))
+	}()
+}
+
+// imageEventData returns data of image.uploaded & image.updated events
+func imageEventData(filename, format string, imgConfig *image.Config) map[string]any {
+	return map[string]any{
+		"filename": filename,
+		"format":   format,
+		"width":    imgConfig.Width,
+		"height":   imgConfig.Height,
+	}
+}
+
+// contentAddressedName returns name of the upload `content` derived from SHA-256
+// of its bytes e.g. `<prefix>ab/cd/abcdef....jpg`, extension is as per the
+// detected image format. `content` is rewound, so that it can be stored next.
+func contentAddressedName(content io.ReadSeeker, prefix string) (string, error) {
+	if strings.Contains(prefix, "..") || strings.HasPrefix(prefix, "/") {
+		return "", fmt.Errorf("%w: invalid prefix", errInvalidUpload)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	header := make([]byte, 12)
+	n, _ := io.ReadFull(content, header)
+	format, err := imagesources.SniffImageFormat(header[:n])
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errInvalidUpload, err.Error())
+	}
+
+	hash := sha256.New()
+	hash.Write(header[:n])
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	return prefix + sum[0:2] + "/" + sum[2:4] + "/" + sum + ext, nil
+}
+
+// statImage returns metadata of `filename` using ImageStater when implemented
+// by `source`, else by reading the image.
+func statImage(ctx context.Context, source kritiimages.ImageSource, filename string) (*kritiimages.ImageStat, error) {
+	if stater, ok := source.(kritiimages.ImageStater); ok {
+		return stater.StatImage(ctx, filename)
+	}
+
+	img, format, err := source.GetImage(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &kritiimages.ImageStat{
+		Name:   filename,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Format: format,
+	}, nil
+}
+
+// saveImage stores the uploaded `file` as `filename` in `source`, see storeImage.
+func saveImage(ctx context.Context, source kritiimages.ImageSource, file *multipart.FileHeader, filename string, normalize bool) (*image.Config, string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	return storeImage(ctx, source, src, file.Size, filename, normalize)
+}
+
+// requestBody returns reader of the request body, bodies are streamed so that
+// large uploads are not held in memory.
+func requestBody(c *fiber.Ctx) io.Reader {
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		return stream
+	}
+	return bytes.NewReader(c.Body())
+}
+
+// storeImage stores `size` bytes read from `src` as `filename` in `source`.
+// By default original bytes are stored as is, when `normalize` is true the
+// image is decoded and re-encoded as per extension of `filename` instead.
+//
+// Returns the image header and format of the uploaded image.
+func storeImage(ctx context.Context, source kritiimages.ImageSource, src io.ReadSeeker, size int64, filename string, normalize bool) (*image.Config, string, error) {
+	if normalize {
+		encoded, err := normalizeImage(src, filename)
+		if err != nil {
+			return nil, "", err
+		}
+		src, size = bytes.NewReader(encoded), int64(len(encoded))
+	}
+
+	imgConfig, format, err := image.DecodeConfig(src)
+	if err != nil {
+		return nil, "", errors.Join(errInvalidUpload, err)
+	}
+	if format != formatFromFilename(filename) {
+		return nil, "", fmt.Errorf("%w: content (%s) does not match file extension", errInvalidUpload, format)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+
+	if err := source.PutImage(ctx, filename, src, size); isInvalidImage(err) {
+		return nil, "", errors.Join(errInvalidUpload, err)
+	} else if err != nil {
+		return nil, "", err
+	}
+	return &imgConfig, format, nil
+}
+
+// isInvalidImage returns true for errors of source image validations, i.e.
+// errors of the image rather than of the source.
+func isInvalidImage(err error) bool {
+	return errors.Is(err, kritiimages.ErrInvalidSourceImage) ||
+		errors.Is(err, kritiimages.ErrSourceImageTooLarge) ||
+		errors.Is(err, kritiimages.ErrSourceImageDimensionsTooLarge)
+}
+
+// normalizeImage decodes the image read from `src` and re-encodes it as sources
+// do on upload, in format of extension of `filename` or in the decoded format
+// when `filename` is empty.
+func normalizeImage(src io.Reader, filename string) ([]byte, error) {
+	img, format, err := image.Decode(src)
+	if err != nil {
+		return nil, errors.Join(errInvalidUpload, err)
+	}
+	if filename == "" {
+		filename = "image." + format
+	}
+
+	encoded := new(bytes.Buffer)
+	if err := imagesources.EncodeImage(encoded, img, filename); err != nil {
+		return nil, errors.Join(errInvalidUpload, err)
+	}
+	return encoded.Bytes(), nil
+}
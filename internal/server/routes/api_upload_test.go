@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+func TestContentAddressedNameOfNormalizedImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := range 16 {
+		for y := range 16 {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+
+	// same pixels, different bytes
+	var fast, best bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&fast, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&best, img); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(fast.Bytes(), best.Bytes()) {
+		t.Fatal("Expected encodings to differ")
+	}
+
+	var names []string
+	for _, upload := range [][]byte{fast.Bytes(), best.Bytes()} {
+		encoded, err := normalizeImage(bytes.NewReader(upload), "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		content := bytes.NewReader(encoded)
+		name, err := contentAddressedName(content, "up")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.HasPrefix(name, "up/") || !strings.HasSuffix(name, ".png") {
+			t.Errorf("Expected name under up/ with .png extension, got %s", name)
+		}
+
+		// content is rewound and stored as hashed
+		stored, _ := io.ReadAll(content)
+		if !bytes.Equal(stored, encoded) {
+			t.Errorf("Expected content to be rewound after hashing")
+		}
+		names = append(names, name)
+	}
+
+	if names[0] != names[1] {
+		t.Errorf("Expected same name for uploads normalizing to same bytes, got %s and %s", names[0], names[1])
+	}
+}
+
+// decodeCountingSource is a local source counting decoded images
+type decodeCountingSource struct {
+	*imagesources.ImageSourceLocal
+	decoded atomic.Int32
+}
+
+func (s *decodeCountingSource) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	s.decoded.Add(1)
+	return s.ImageSourceLocal.GetImage(ctx, fileName)
+}
+
+func TestUpdateImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte
+		expected int
+	}{
+		{name: "existing", existing: testPNG(t, 4), expected: http.StatusOK},
+		{name: "existing invalid", existing: []byte("not an image"), expected: http.StatusOK},
+		{name: "missing", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath := t.TempDir()
+			source := &decodeCountingSource{ImageSourceLocal: kritiimages.NewImageSourceLocal(basePath, &imagesources.SourceImageValidations{MaxImageDimension: 100, MaxFileSizeInBytes: 1024 * 1024})}
+			if tt.existing != nil {
+				if err := os.WriteFile(filepath.Join(basePath, "a.png"), tt.existing, 0644); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			app := fiber.New()
+			BindAPIUpload(app, kritiimages.New(map[string]kritiimages.ImageSource{"local": source}, source), &config.APIConfig{}, nil, nil, nil, nil)
+
+			body := new(bytes.Buffer)
+			form := multipart.NewWriter(body)
+			header := make(map[string][]string)
+			header["Content-Disposition"] = []string{`form-data; name="image"; filename="a.png"`}
+			header["Content-Type"] = []string{"image/png"}
+			part, _ := form.CreatePart(header)
+			part.Write(testPNG(t, 8))
+			form.WriteField("filename", "a.png")
+			form.Close()
+
+			req := httptest.NewRequest(http.MethodPut, "/api/v0/images", body)
+			req.Header.Set(fiber.HeaderContentType, form.FormDataContentType())
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+			if decoded := source.decoded.Load(); decoded != 0 {
+				t.Errorf("Expected existing image not to be decoded, got %d decodes", decoded)
+			}
+		})
+	}
+}
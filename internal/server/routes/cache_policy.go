@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kritihq/kriti-images/internal/config"
+)
+
+// resolveCachePolicy returns the cache policy of a transformed image, overrides
+// of `source`, longest path prefix matching `imagePath` and `presets` are applied
+// over the default policy in that order.
+func resolveCachePolicy(cfg *config.ImagesConfigCachePolicy, source, imagePath string, presets []string) config.CachePolicy {
+	policy := cfg.Default
+	if override, ok := cfg.Sources[source]; ok {
+		policy = mergeCachePolicy(policy, override)
+	}
+
+	var matched *config.CachePolicyPath
+	for i, path := range cfg.Paths {
+		if strings.HasPrefix(imagePath, path.Prefix) && (matched == nil || len(path.Prefix) > len(matched.Prefix)) {
+			matched = &cfg.Paths[i]
+		}
+	}
+	if matched != nil {
+		policy = mergeCachePolicy(policy, matched.CachePolicy)
+	}
+
+	for _, preset := range presets {
+		if override, ok := cfg.Presets[preset]; ok {
+			policy = mergeCachePolicy(policy, override)
+		}
+	}
+	return policy
+}
+
+// mergeCachePolicy returns `base` with non-empty fields of `override`.
+func mergeCachePolicy(base, override config.CachePolicy) config.CachePolicy {
+	if override.CacheControl != "" {
+		base.CacheControl = override.CacheControl
+	}
+	if override.Robots != nil {
+		base.Robots = override.Robots
+	}
+	if override.AllowOrigin != "" {
+		base.AllowOrigin = override.AllowOrigin
+	}
+	return base
+}
+
+// applyCachePolicy sets headers of `policy`, Expires is derived from max-age
+// of Cache-Control for old HTTP/1.0 caches.
+func applyCachePolicy(c *fiber.Ctx, policy config.CachePolicy) {
+	if policy.CacheControl != "" {
+		c.Set("Cache-Control", policy.CacheControl)
+		if maxAge, ok := maxAgeOf(policy.CacheControl); ok {
+			c.Set("Expires", time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+		}
+	}
+	if policy.Robots != nil && *policy.Robots {
+		c.Set("X-Robots-Tag", "noindex, nofollow")
+	}
+	if policy.AllowOrigin != "" {
+		c.Set("Access-Control-Allow-Origin", policy.AllowOrigin)
+	}
+}
+
+// sendError sends `message` with cache policy of the `status`; not_found policy
+// for 404 and error policy otherwise.
+func sendError(c *fiber.Ctx, cfg *config.ImagesConfigCachePolicy, status int, message string) error {
+	policy := cfg.Error
+	if status == http.StatusNotFound {
+		policy = cfg.NotFound
+	}
+	if policy.CacheControl != "" {
+		c.Set("Cache-Control", policy.CacheControl)
+	}
+	return c.Status(status).SendString(message)
+}
+
+// maxAgeOf returns value of max-age directive in `cacheControl`.
+func maxAgeOf(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// usedPresets returns names of presets in options string, before canonicalOptions
+// expands them.
+func usedPresets(optionsStr string) []string {
+	presets := make([]string, 0)
+	for _, optStr := range splitOptions(optionsStr) {
+		key, value, _ := strings.Cut(optStr, "=")
+		if strings.TrimSpace(key) == "preset" {
+			presets = append(presets, strings.TrimSpace(value))
+		}
+	}
+	return presets
+}
+
+// sourceName returns name of the source serving `imagePath`, same as KritiImages
+// resolves it.
+func sourceName(cfg *config.ImagesConfig, imagePath string) string {
+	if strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://") {
+		return "http"
+	}
+	return cfg.Source
+}
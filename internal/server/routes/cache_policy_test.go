@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/kritihq/kriti-images/internal/config"
+)
+
+func TestResolveCachePolicy(t *testing.T) {
+	robots, noRobots := true, false
+	cfg := &config.ImagesConfigCachePolicy{
+		Default: config.CachePolicy{CacheControl: "public, max-age=31536000, immutable", Robots: &robots, AllowOrigin: "*"},
+		Sources: map[string]config.CachePolicy{
+			"http": {CacheControl: "public, max-age=3600"},
+		},
+		Paths: []config.CachePolicyPath{
+			{Prefix: "banners/", CachePolicy: config.CachePolicy{CacheControl: "public, max-age=300, stale-while-revalidate=60"}},
+			{Prefix: "banners/home/", CachePolicy: config.CachePolicy{CacheControl: "public, max-age=60"}},
+		},
+		Presets: map[string]config.CachePolicy{
+			"og": {Robots: &noRobots},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		source       string
+		imagePath    string
+		presets      []string
+		cacheControl string
+		robots       bool
+	}{
+		{
+			name:         "default",
+			source:       "local",
+			imagePath:    "products/shoe.jpg",
+			cacheControl: "public, max-age=31536000, immutable",
+			robots:       true,
+		},
+		{
+			name:         "source override",
+			source:       "http",
+			imagePath:    "https://example.com/a.jpg",
+			cacheControl: "public, max-age=3600",
+			robots:       true,
+		},
+		{
+			name:         "longest path prefix",
+			source:       "local",
+			imagePath:    "banners/home/hero.jpg",
+			cacheControl: "public, max-age=60",
+			robots:       true,
+		},
+		{
+			name:         "preset inherits path policy",
+			source:       "local",
+			imagePath:    "banners/sale.jpg",
+			presets:      []string{"og"},
+			cacheControl: "public, max-age=300, stale-while-revalidate=60",
+			robots:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := resolveCachePolicy(cfg, tt.source, tt.imagePath, tt.presets)
+
+			if policy.CacheControl != tt.cacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.cacheControl, policy.CacheControl)
+			}
+			if *policy.Robots != tt.robots {
+				t.Errorf("Expected robots %v, got %v", tt.robots, *policy.Robots)
+			}
+			if policy.AllowOrigin != "*" {
+				t.Errorf("Expected allow origin *, got %q", policy.AllowOrigin)
+			}
+		})
+	}
+}
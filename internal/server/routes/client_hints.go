@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientHints are requested from browsers using Accept-CH, they are used to
+// resolve `width=auto`.
+var clientHints = []string{"Sec-CH-DPR", "Sec-CH-Width", "Sec-CH-Viewport-Width"}
+
+// resolveClientHints replaces `width=auto` in canonical options with width from
+// client hints in CSS pixels, Sec-CH-Width (divided by DPR) is preferred over
+// Sec-CH-Viewport-Width. When `dpr` option is not present, `dpr` from Sec-CH-DPR
+// is added. Resolved options are canonical, so they can be used as cache key.
+//
+// `width=auto` is kept when no width hint is present, the source width is then used.
+// Returns true when options depend on client hints.
+func resolveClientHints(c *fiber.Ctx, optionsStr string) (string, bool) {
+	options := splitOptions(optionsStr)
+	autoWidth, hasDPR := -1, false
+	for i, optStr := range options {
+		key, value, _ := strings.Cut(optStr, "=")
+		if key == "width" && value == "auto" {
+			autoWidth = i
+		} else if key == "dpr" {
+			hasDPR = true
+		}
+	}
+	if autoWidth < 0 {
+		return optionsStr, false
+	}
+
+	dpr := parseHint(c.Get("Sec-CH-DPR"), 1, 4)
+	var width float64
+	if hint := parseHint(c.Get("Sec-CH-Width"), 1, 10000); hint > 0 {
+		width = hint / max(dpr, 1)
+	} else {
+		width = parseHint(c.Get("Sec-CH-Viewport-Width"), 1, 10000)
+	}
+	if width <= 0 {
+		return optionsStr, true
+	}
+
+	options[autoWidth] = "width=" + strconv.Itoa(int(math.Round(width)))
+	if !hasDPR && dpr > 1 {
+		// kept sorted by option name, see canonicalOptions
+		options = append(options, "dpr="+strconv.FormatFloat(dpr, 'f', -1, 64))
+		sortOptions(options)
+	}
+	return strings.Join(options, ","), true
+}
+
+// setClientHintsHeaders asks browsers to send client hints, responses varying
+// by them are marked so that caches keep a variant per hint value.
+func setClientHintsHeaders(c *fiber.Ctx, usesHints bool) {
+	c.Set("Accept-CH", strings.Join(clientHints, ", "))
+	if usesHints {
+		c.Vary(clientHints...)
+	}
+}
+
+// parseHint returns value of a numeric client hint, 0 if it is missing or out of range.
+func parseHint(value string, min, max float64) float64 {
+	hint, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || hint < min || hint > max {
+		return 0
+	}
+	return hint
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestResolveClientHints(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   string
+		headers   map[string]string
+		expected  string
+		usesHints bool
+	}{
+		{
+			name:     "explicit width",
+			options:  "width=300",
+			headers:  map[string]string{"Sec-CH-Width": "800"},
+			expected: "width=300",
+		},
+		{
+			name:      "width hint with dpr",
+			options:   "format=webp,width=auto",
+			headers:   map[string]string{"Sec-CH-Width": "800", "Sec-CH-DPR": "2"},
+			expected:  "dpr=2,format=webp,width=400",
+			usesHints: true,
+		},
+		{
+			name:      "viewport width hint",
+			options:   "width=auto",
+			headers:   map[string]string{"Sec-CH-Viewport-Width": "375"},
+			expected:  "width=375",
+			usesHints: true,
+		},
+		{
+			name:      "dpr option overrides hint",
+			options:   "dpr=3,width=auto",
+			headers:   map[string]string{"Sec-CH-Viewport-Width": "375", "Sec-CH-DPR": "2"},
+			expected:  "dpr=3,width=375",
+			usesHints: true,
+		},
+		{
+			name:      "no hints",
+			options:   "width=auto",
+			headers:   map[string]string{},
+			expected:  "width=auto",
+			usesHints: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				result, usesHints := resolveClientHints(c, tt.options)
+				if result != tt.expected {
+					t.Errorf("Expected %s, got %s", tt.expected, result)
+				}
+				if usesHints != tt.usesHints {
+					t.Errorf("Expected usesHints=%v, got %v", tt.usesHints, usesHints)
+				}
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDPR(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  string
+		width    int
+		height   int
+		hasError bool
+	}{
+		{name: "scales width and height", options: "dpr=2,height=150,width=100", width: 200, height: 300},
+		{name: "fractional dpr", options: "dpr=1.5,width=101", width: 152},
+		{name: "limited to maximum dimension", options: "dpr=4,width=9000", width: 10000},
+		{name: "out of range", options: "dpr=5,width=100", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, dest, err := getContextFromString(tt.options)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if dest.Width != tt.width || dest.Height != tt.height {
+				t.Errorf("Expected %dx%d, got %dx%d", tt.width, tt.height, dest.Width, dest.Height)
+			}
+		})
+	}
+}
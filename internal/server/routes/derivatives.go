@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/events"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+const derivativeQueueSize = 100
+
+// variant is a derivative of an uploaded image, as sent in upload responses.
+// Queued is false when the queue was full, it is generated on first request then.
+type variant struct {
+	Options string `json:"options"`
+	URL     string `json:"url"`
+	Queued  bool   `json:"queued"`
+}
+
+// derivativeQueue generates configured derivatives of uploaded images in
+// background and stores them in the transform cache, so that first request
+// of a derivative is served from the cache.
+type derivativeQueue struct {
+	k              *kritiimages.KritiImages
+	transformCache *cache.Cache
+	dispatcher     *events.Dispatcher
+	presets        map[string]string
+	derivatives    []string
+	images         chan string
+}
+
+// newDerivativeQueue starts `workers` goroutines generating `derivatives` i.e.
+// preset names or transformation options. Panics on invalid derivatives, so
+// that configuration errors are caught on startup.
+func newDerivativeQueue(k *kritiimages.KritiImages, transformCache *cache.Cache, dispatcher *events.Dispatcher, presets map[string]string, derivatives []string, workers int) *derivativeQueue {
+	if transformCache == nil {
+		panic("images.cache.enabled is required for api.upload.derivatives")
+	}
+
+	q := &derivativeQueue{
+		k:              k,
+		transformCache: transformCache,
+		dispatcher:     dispatcher,
+		presets:        presets,
+		derivatives:    make([]string, 0, len(derivatives)),
+		images:         make(chan string, derivativeQueueSize),
+	}
+	for _, derivative := range derivatives {
+		if _, ok := presets[derivative]; ok {
+			derivative = "preset=" + derivative
+		}
+
+		optionsStr, err := canonicalOptions(derivative, presets)
+		if err == nil {
+			_, _, err = getContextFromString(optionsStr)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("invalid derivative %s; %s", derivative, err.Error()))
+		}
+		q.derivatives = append(q.derivatives, derivative)
+	}
+
+	for range max(workers, 1) {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue schedules generation of derivatives of `imagePath` and returns the
+// derivative URLs relative to `baseURL`. Derivatives are skipped when the queue
+// is full, variants are then reported as not queued.
+func (q *derivativeQueue) Enqueue(baseURL, imagePath string) []variant {
+	imagePath = kritiimages.CleanPath(imagePath)
+	queued := true
+	select {
+	case q.images <- imagePath:
+	default:
+		queued = false
+		log.Warnw("derivative queue is full, skipping derivatives", "path", imagePath)
+	}
+
+	variants := make([]variant, 0, len(q.derivatives))
+	for _, derivative := range q.derivatives {
+		variants = append(variants, variant{
+			Options: derivative,
+			URL:     baseURL + "/cgi/images/tr:" + derivative + "/" + url.PathEscape(imagePath),
+			Queued:  queued,
+		})
+	}
+	return variants
+}
+
+func (q *derivativeQueue) work() {
+	for imagePath := range q.images {
+		for _, derivative := range q.derivatives {
+			// options are validated in newDerivativeQueue
+			optionsStr, options, dest, _ := resolveVariant(context.Background(), q.k, q.presets, imagePath, derivative)
+
+			version, err := q.k.Version(context.Background(), imagePath)
+			if err != nil {
+				log.Errorw("failed to generate derivative", "path", imagePath, "options", derivative, "error", err.Error())
+				continue
+			}
+
+			if _, _, err := transformImage(context.Background(), q.k, q.transformCache, imagePath, optionsStr, options, dest, version); err != nil {
+				log.Errorw("failed to generate derivative", "path", imagePath, "options", derivative, "error", err.Error())
+				continue
+			}
+			log.Infow("derivative generated", "path", imagePath, "options", derivative)
+			q.dispatcher.Emit(events.VariantGenerated, map[string]any{"filename": imagePath, "options": derivative})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+func TestDerivativesServedFromCache(t *testing.T) {
+	basePath := t.TempDir()
+	for _, name := range []string{"a.png", "logo.png"} {
+		if err := os.WriteFile(filepath.Join(basePath, name), testPNG(t, 4), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	source := &decodeCountingSource{ImageSourceLocal: kritiimages.NewImageSourceLocal(basePath, &imagesources.SourceImageValidations{MaxImageDimension: 100, MaxFileSizeInBytes: 1024 * 1024})}
+	k := kritiimages.New(map[string]kritiimages.ImageSource{"local": source}, source)
+	transformCache, err := cache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// overlay versions are part of the cache key
+	presets := map[string]string{"thumb": "width=2,overlay=logo.png"}
+	q := newDerivativeQueue(k, transformCache, nil, presets, []string{"thumb"}, 1)
+	variants := q.Enqueue("", "a.png")
+
+	key, _, _, _ := resolveVariant(context.Background(), k, presets, "a.png", "preset=thumb")
+	version, _ := k.Version(context.Background(), "a.png")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := transformCache.Get("a.png", key+"@"+version.ETag); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected derivative to be generated")
+		}
+	}
+	source.decoded.Store(0)
+
+	app := fiber.New()
+	BindRouteTransformation(app, k, &config.ImagesConfig{Source: "local", Presets: presets}, transformCache)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, variants[0].URL, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if decoded := source.decoded.Load(); decoded != 0 {
+		t.Errorf("Expected derivative to be served from cache, got %d decodes", decoded)
+	}
+}
+
+func TestEnqueueReportsFullQueue(t *testing.T) {
+	// no workers, queue is full after first image
+	q := &derivativeQueue{derivatives: []string{"preset=thumb"}, images: make(chan string, 1)}
+
+	tests := []struct {
+		name     string
+		image    string
+		expected bool
+	}{
+		{name: "queued", image: "a.png", expected: true},
+		{name: "queue full", image: "b.png", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants := q.Enqueue("http://localhost", tt.image)
+			if len(variants) != 1 {
+				t.Fatalf("Expected 1 variant, got %v", variants)
+			}
+			if variants[0].Queued != tt.expected {
+				t.Errorf("Expected queued=%v, got %v", tt.expected, variants[0].Queued)
+			}
+			if expected := "http://localhost/cgi/images/tr:preset=thumb/" + tt.image; variants[0].URL != expected {
+				t.Errorf("Expected URL %s, got %s", expected, variants[0].URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+// infoOptions is used in place of canonical options to derive ETag of image info
+const infoOptions = "format=json"
+
+// BindRouteImageInfo serves intrinsic properties of source images as JSON,
+// e.g. to reserve layout space before the image is loaded. Same is served by
+// transform route for `format=json`.
+func BindRouteImageInfo(server *fiber.App, k *kritiimages.KritiImages, cfg *config.ImagesConfig) {
+	server.Get("/cgi/images/info/:image", func(c *fiber.Ctx) error {
+		imagePath, err := url.PathUnescape(c.Params("image", ""))
+		if err != nil {
+			log.Warn("failed to unescape image path, using original value", "path", imagePath)
+			imagePath = c.Params("image", "")
+		}
+		imagePath = kritiimages.CleanPath(imagePath)
+		if imagePath == "" {
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "image parameter is required")
+		}
+
+		policy := resolveCachePolicy(&cfg.CachePolicy, sourceName(cfg, imagePath), imagePath, nil)
+		return sendImageInfo(c, k, cfg, imagePath, policy)
+	})
+}
+
+// sendImageInfo responds with kritiimages.ImageInfo of `imagePath`, conditional
+// requests are answered using version of the source image.
+func sendImageInfo(c *fiber.Ctx, k *kritiimages.KritiImages, cfg *config.ImagesConfig, imagePath string, policy config.CachePolicy) error {
+	version, err := k.Version(c.Context(), imagePath)
+	if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+		return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
+	} else if err != nil {
+		log.Warnw("failed to get image version", "path", imagePath, "error", err.Error())
+		version = nil
+	}
+
+	etag := ""
+	if version != nil {
+		etag = imageETag(version, infoOptions)
+		if isNotModified(c, etag, version.ModTime) {
+			setCacheHeaders(c, imagePath, version, etag, policy)
+			return c.SendStatus(http.StatusNotModified)
+		}
+	}
+
+	info, err := k.Info(c.Context(), imagePath)
+	if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+		return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
+	} else if err != nil {
+		log.Errorw("failed to get image info", "path", imagePath, "error", err.Error())
+		return sendError(c, &cfg.CachePolicy, http.StatusInternalServerError, "failed to get image info")
+	}
+
+	setCacheHeaders(c, imagePath, version, etag, policy)
+	return c.Status(http.StatusOK).JSON(info)
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+// BindRouteTemplate renders templates of KritiImages.Templates with slot values
+// from query parameters, e.g. /cgi/images/template:og-product?title=Shoe&image=products%2Fshoe.jpg
+func BindRouteTemplate(server *fiber.App, k *kritiimages.KritiImages, cfg *config.ImagesConfig, transformCache *cache.Cache) {
+	server.Get(`/cgi/images/template\::name`, func(c *fiber.Ctx) error {
+		name := c.Params("name", "")
+		template, err := k.Template(name)
+		if err != nil {
+			return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "template not found")
+		}
+
+		// only slots of the template are used, so that unknown parameters do not change the cache key
+		queries := c.Queries()
+		slots, values := make(map[string]string), url.Values{}
+		for _, slot := range template.Slots() {
+			if value, ok := queries[slot]; ok {
+				slots[slot] = value
+				values.Set(slot, value)
+			}
+		}
+		cacheKey := templatePath(name) + "?" + values.Encode()
+		policy := resolveCachePolicy(&cfg.CachePolicy, "", templatePath(name), nil)
+
+		version, err := k.TemplateVersion(c.Context(), template, slots)
+		if errors.Is(err, kritiimages.ErrMissingTemplateSlot) {
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, err.Error())
+		} else if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
+		} else if err != nil {
+			log.Warnw("failed to get template version", "template", name, "error", err.Error())
+			version = nil
+		}
+		if version != nil {
+			etag := imageETag(version, cacheKey)
+			if isNotModified(c, etag, version.ModTime) {
+				setCacheHeaders(c, templatePath(name), version, etag, policy)
+				return c.SendStatus(http.StatusNotModified)
+			}
+		}
+
+		data, err := renderTemplate(c.Context(), k, transformCache, name, cacheKey, template, slots, version)
+		if errors.Is(err, kritiimages.ErrMissingTemplateSlot) {
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, err.Error())
+		} else if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
+		} else if errors.Is(err, kritiimages.ErrTransformationsNotFound) {
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "invalid template")
+		} else if err != nil {
+			log.Errorw("failed to render template", "template", name, "error", err.Error())
+			return sendError(c, &cfg.CachePolicy, http.StatusInternalServerError, "failed to render template")
+		}
+
+		etag := ""
+		if version != nil {
+			etag = imageETag(version, cacheKey)
+		}
+		setCacheHeaders(c, templatePath(name), version, etag, policy)
+
+		format, err := imagesources.SniffImageFormat(data)
+		if err != nil {
+			// placeholder formats are text
+			c.Set("Content-Type", fiber.MIMETextPlainCharsetUTF8)
+		} else {
+			c.Set("Content-Type", "image/"+strings.ToLower(format))
+		}
+		return c.Status(http.StatusOK).Send(data)
+	})
+}
+
+// templatePath is used in place of image path of templates, e.g. as cache
+// directory and surrogate key, it can not collide with image paths.
+func templatePath(name string) string {
+	return fmt.Sprintf("template:%s", name)
+}
+
+// renderTemplate returns the rendered template, served from `transformCache`
+// when present, see transformImage.
+func renderTemplate(ctx context.Context, k *kritiimages.KritiImages, transformCache *cache.Cache, name, cacheKey string, template *kritiimages.Template, slots map[string]string, version *kritiimages.ImageVersion) ([]byte, error) {
+	if version != nil {
+		cacheKey += "@" + version.ETag
+	}
+
+	var cacheVersion uint64
+	if transformCache != nil {
+		cacheVersion = transformCache.Version(templatePath(name))
+		data, err := transformCache.Get(templatePath(name), cacheKey)
+		if err == nil {
+			return data, nil
+		} else if !errors.Is(err, cache.ErrCacheMiss) {
+			log.Warnw("failed to read rendered template from cache", "template", name, "error", err.Error())
+		}
+	}
+
+	buffer, err := k.Render(ctx, template, slots)
+	if err != nil {
+		return nil, err
+	}
+
+	if transformCache != nil {
+		if err := transformCache.Set(templatePath(name), cacheKey, buffer.Bytes(), cacheVersion); err != nil {
+			log.Warnw("failed to cache rendered template", "template", name, "error", err.Error())
+		}
+	}
+	return buffer.Bytes(), nil
+}
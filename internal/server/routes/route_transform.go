@@ -1,21 +1,31 @@
 package routes
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"image/color"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
+	"github.com/kritihq/kriti-images/internal/cache"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
 	"github.com/kritihq/kriti-images/internal/utils"
 	"github.com/kritihq/kriti-images/pkg/kritiimages"
 )
 
-func BindRouteTransformation(server *fiber.App, k *kritiimages.KritiImages) {
+func BindRouteTransformation(server *fiber.App, k *kritiimages.KritiImages, cfg *config.ImagesConfig, transformCache *cache.Cache) {
 	server.Get(`/cgi/images/tr\::options?/:image`, func(c *fiber.Ctx) error {
 		optionsStr := c.Params("options", "")
 		imagePath, err := url.PathUnescape(c.Params("image", ""))
@@ -23,63 +33,307 @@ func BindRouteTransformation(server *fiber.App, k *kritiimages.KritiImages) {
 			log.Warn("failed to unescape image path, using original value", "path", imagePath)
 			imagePath = c.Params("image", "")
 		}
+		imagePath = kritiimages.CleanPath(imagePath)
 		log.Infow("new request", "options", optionsStr, "path", imagePath)
 
 		if optionsStr == "" {
-			return c.Status(http.StatusBadRequest).SendString("options parameter is required")
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "options parameter is required")
 		}
 
 		if imagePath == "" {
-			return c.Status(http.StatusBadRequest).SendString("image parameter is required")
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "image parameter is required")
 		}
 
 		// Parse transformation context
-		options, dest, err := getContextFromString(optionsStr)
+		rawOptions := optionsStr
+		optionsStr, err = canonicalOptions(optionsStr, cfg.Presets)
 		if err != nil {
 			log.Errorw("failed to transform image", "options", optionsStr, "path", imagePath, "error", err.Error())
-			return c.Status(http.StatusInternalServerError).SendString(fmt.Sprintf("failed to process the request; %s", err.Error()))
+			return sendError(c, &cfg.CachePolicy, http.StatusInternalServerError, fmt.Sprintf("failed to process the request; %s", err.Error()))
+		}
+		optionsStr, usesHints := resolveClientHints(c, optionsStr)
+		policy := resolveCachePolicy(&cfg.CachePolicy, sourceName(cfg, imagePath), imagePath, usedPresets(rawOptions))
+		// canonical options are kept as is, including widths of client hints
+		optionsStr, options, dest, err := resolveVariant(c.Context(), k, cfg.Presets, imagePath, optionsStr)
+		if err != nil {
+			log.Errorw("failed to transform image", "options", optionsStr, "path", imagePath, "error", err.Error())
+			return sendError(c, &cfg.CachePolicy, http.StatusInternalServerError, fmt.Sprintf("failed to process the request; %s", err.Error()))
+		}
+		if dest.Format == "json" && dest.Palette == 0 {
+			// info of the source image, other transformations are ignored
+			return sendImageInfo(c, k, cfg, imagePath, policy)
 		}
 
-		buffer, err := k.Transform(c.Context(), imagePath, dest, options)
+		// answer conditional requests before fetching or transforming the image
+		version, err := k.Version(c.Context(), imagePath)
 		if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
-			return c.Status(http.StatusNotFound).SendString("image not found")
+			return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
+		} else if err != nil {
+			log.Warnw("failed to get image version", "path", imagePath, "error", err.Error())
+			version = nil
+		}
+		if version != nil {
+			etag := imageETag(version, optionsStr)
+			if isNotModified(c, etag, version.ModTime) {
+				setCacheHeaders(c, imagePath, version, etag, policy)
+				setClientHintsHeaders(c, usesHints)
+				return c.SendStatus(http.StatusNotModified)
+			}
+		}
+
+		data, format, err := transformImage(c.Context(), k, transformCache, imagePath, optionsStr, options, dest, version)
+		if errors.Is(err, kritiimages.ErrSourceImageNotFound) {
+			return sendError(c, &cfg.CachePolicy, http.StatusNotFound, "image not found")
 		} else if errors.Is(err, kritiimages.ErrTransformationsNotFound) {
-			return c.Status(http.StatusBadRequest).SendString("invalid transformation requested")
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "invalid transformation requested")
 		} else if errors.Is(err, kritiimages.ErrInvalidImageFormat) {
-			return c.Status(http.StatusBadRequest).SendString("invalid image format requested")
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "invalid image format requested")
 		} else if err != nil {
-			return c.Status(http.StatusInternalServerError).SendString("failed to transform image")
+			return sendError(c, &cfg.CachePolicy, http.StatusInternalServerError, "failed to transform image")
 		}
 
-		format := dest.Format
+		contentType := ""
 		switch strings.ToLower(format) {
 		case "jpg", "jpeg":
-			c.Set("Content-Type", "image/jpeg")
+			contentType = "image/jpeg"
 		case "png":
-			c.Set("Content-Type", "image/png")
+			contentType = "image/png"
 		case "webp":
-			c.Set("Content-Type", "image/webp")
+			contentType = "image/webp"
+		case "blurhash", "thumbhash", "datauri":
+			contentType = fiber.MIMETextPlainCharsetUTF8
+		case "json":
+			contentType = fiber.MIMEApplicationJSONCharsetUTF8
 		default:
-			return c.Status(http.StatusBadRequest).SendString("invalid image format requested")
+			return sendError(c, &cfg.CachePolicy, http.StatusBadRequest, "invalid image format requested")
+		}
+
+		etag := ""
+		if version != nil {
+			etag = imageETag(version, optionsStr)
+		}
+		setCacheHeaders(c, imagePath, version, etag, policy)
+		setClientHintsHeaders(c, usesHints)
+
+		// placeholders are sent as JSON when preferred, e.g. {"blurhash": "LEHV6n..."}
+		if kritiimages.IsPlaceholderFormat(format) && c.Accepts(fiber.MIMETextPlain, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+			return c.Status(http.StatusOK).JSON(fiber.Map{strings.ToLower(format): string(data)})
+		}
+		c.Set("Content-Type", contentType)
+		return c.Status(http.StatusOK).Send(data)
+	})
+}
+
+// resolveVariant parses `optionsStr` of a variant of `imagePath`, presets are
+// expanded. Returned options identify the variant in the transform cache and
+// its ETag: canonical options with the watermark and versions of overlay images.
+// Transform route and derivatives must resolve variants using it, so that
+// derivatives are served from the cache.
+func resolveVariant(ctx context.Context, k *kritiimages.KritiImages, presets map[string]string, imagePath, optionsStr string) (string, map[kritiimages.TransformationOption]string, *kritiimages.DestinationImage, error) {
+	optionsStr, err := canonicalOptions(optionsStr, presets)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	options, dest, err := getContextFromString(optionsStr)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	optionsStr = withWatermark(k, imagePath, optionsStr)
+	optionsStr = withOverlayVersions(ctx, k, imagePath, optionsStr, dest)
+	return optionsStr, options, dest, nil
+}
+
+// withWatermark appends the watermark of source of `imagePath` to canonical
+// options, so that ETag and cache key change with the watermark.
+func withWatermark(k *kritiimages.KritiImages, imagePath, optionsStr string) string {
+	if watermark := k.Watermark(imagePath); watermark != nil {
+		return optionsStr + ";watermark:" + watermark.String()
+	}
+	return optionsStr
+}
+
+// withOverlayVersions appends versions of overlay, mask and watermark images to
+// canonical options, so that ETag and cache key change when these images are
+// modified. Watermarks of overlay and mask sources are appended too, as they are
+// drawn over them. Images of sources without versions are not tracked.
+func withOverlayVersions(ctx context.Context, k *kritiimages.KritiImages, imagePath, optionsStr string, dest *kritiimages.DestinationImage) string {
+	paths := make([]string, 0, len(dest.Overlays)+2)
+	for _, overlay := range dest.Overlays {
+		paths = append(paths, overlay.Path)
+	}
+	if dest.Mask != "" && dest.Mask != "circle" && dest.Mask != "ellipse" {
+		paths = append(paths, dest.Mask)
+	}
+	watermarks := make([]string, 0, len(paths)+1)
+	for _, path := range paths {
+		if watermark := k.Watermark(path); watermark != nil {
+			optionsStr += ";watermark:" + path + ":" + watermark.String()
+			watermarks = append(watermarks, watermark.Path)
+		}
+	}
+	if watermark := k.Watermark(imagePath); watermark != nil {
+		watermarks = append(watermarks, watermark.Path)
+	}
+
+	for _, path := range append(paths, watermarks...) {
+		version, err := k.Version(ctx, path)
+		if err != nil || version == nil {
+			continue
 		}
+		optionsStr += ";version:" + path + "@" + version.ETag
+	}
+	return optionsStr
+}
 
-		// Set CDN-friendly caching headers
-		c.Set("Cache-Control", "public, max-age=31536000, immutable") // 1 year cache
-		c.Set("Expires", time.Now().Add(time.Hour*24*365).UTC().Format(http.TimeFormat))
+// setCacheHeaders sets caching headers of transformed images as per `policy`.
+// `version` is nil for sources without versions, Last-Modified is then the current
+// time and ETag is generated by the etag middleware from the response body.
+func setCacheHeaders(c *fiber.Ctx, imagePath string, version *kritiimages.ImageVersion, etag string, policy config.CachePolicy) {
+	applyCachePolicy(c, policy)
+	if version != nil {
+		c.Set("ETag", etag)
+		c.Set("Last-Modified", version.ModTime.UTC().Format(http.TimeFormat))
+	} else {
 		c.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	}
+
+	// Add Vary header to ensure CDN caches different versions properly
+	c.Set("Vary", "Accept")
+
+	// Security headers for CDN
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set("Content-Security-Policy", "default-src 'none'")
+
+	// Add CDN-specific headers, tags allow purging all variants of the image
+	surrogateKey := cdn.SurrogateKey(imagePath)
+	c.Set("Surrogate-Key", surrogateKey)
+	c.Set("Cache-Tag", surrogateKey)
+}
+
+// imageETag returns a strong ETag for transformed image of source image `version`
+// using canonical options, it changes when either changes.
+func imageETag(version *kritiimages.ImageVersion, optionsStr string) string {
+	sum := sha256.Sum256([]byte(version.ETag + "\n" + optionsStr))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// isNotModified returns true when conditional request headers match the current
+// `etag` or `modTime`. If-Modified-Since is ignored when If-None-Match is present.
+func isNotModified(c *fiber.Ctx, etag string, modTime time.Time) bool {
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		for _, candidate := range strings.Split(noneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modifiedSince := c.Get(fiber.HeaderIfModifiedSince); modifiedSince != "" {
+		since, err := http.ParseTime(modifiedSince)
+		return err == nil && !modTime.Truncate(time.Second).After(since)
+	}
+	return false
+}
 
-		// Add Vary header to ensure CDN caches different versions properly
-		c.Set("Vary", "Accept")
+// transformImage returns the transformed image and its format. When `transformCache`
+// is not nil, image is served from the cache if present, else cached after transformation.
+// `optionsStr` must be canonical, see canonicalOptions. Cached images are keyed
+// by `version` of the source image too, when not nil, so that images modified
+// directly in the source are not served stale.
+func transformImage(ctx context.Context, k *kritiimages.KritiImages, transformCache *cache.Cache, imagePath, optionsStr string, options map[kritiimages.TransformationOption]string, dest *kritiimages.DestinationImage, version *kritiimages.ImageVersion) ([]byte, string, error) {
+	cacheKey := optionsStr
+	if version != nil {
+		cacheKey += "@" + version.ETag
+	}
 
-		// Security headers for CDN
-		c.Set("X-Content-Type-Options", "nosniff")
-		c.Set("Content-Security-Policy", "default-src 'none'")
+	var cacheVersion uint64
+	if transformCache != nil {
+		cacheVersion = transformCache.Version(imagePath)
+		data, err := transformCache.Get(imagePath, cacheKey)
+		if err == nil {
+			format, err := imagesources.SniffImageFormat(data)
+			if err != nil && kritiimages.IsTextFormat(dest.Format) {
+				format, err = dest.Format, nil
+			}
+			if err == nil {
+				return data, format, nil
+			}
+		} else if !errors.Is(err, cache.ErrCacheMiss) {
+			log.Warnw("failed to read transformed image from cache", "path", imagePath, "options", optionsStr, "error", err.Error())
+		}
+	}
 
-		// Add CDN-specific headers
-		c.Set("X-Robots-Tag", "noindex, nofollow")
-		c.Set("Access-Control-Allow-Origin", "*")
+	buffer, err := k.Transform(ctx, imagePath, dest, options)
+	if err != nil {
+		return nil, "", err
+	}
 
-		return c.Status(http.StatusOK).Send(buffer.Bytes())
+	if transformCache != nil {
+		if err := transformCache.Set(imagePath, cacheKey, buffer.Bytes(), cacheVersion); err != nil {
+			log.Warnw("failed to cache transformed image", "path", imagePath, "options", optionsStr, "error", err.Error())
+		}
+	}
+	return buffer.Bytes(), dest.Format, nil
+}
+
+// canonicalOptions replaces `preset=<name>` options with transformations of the
+// named preset and sorts options by name, so that same transformations always
+// result in the same string. Relative order of repeated options is kept, the
+// last one takes effect e.g. `preset=thumb,format=png` overrides format of thumb.
+//
+// return meaningful errors, they are sent as response as is
+func canonicalOptions(optionsStr string, presets map[string]string) (string, error) {
+	options := make([]string, 0)
+	for _, optStr := range splitOptions(optionsStr) {
+		key, value, _ := strings.Cut(optStr, "=")
+		if strings.TrimSpace(key) != "preset" {
+			options = append(options, strings.TrimSpace(optStr))
+			continue
+		}
+
+		preset, ok := presets[strings.TrimSpace(value)]
+		if !ok {
+			return "", fmt.Errorf("unknown preset: %s", value)
+		}
+		for _, presetOpt := range splitOptions(preset) {
+			options = append(options, strings.TrimSpace(presetOpt))
+		}
+	}
+
+	sortOptions(options)
+	return strings.Join(options, ","), nil
+}
+
+// flagOptions are options which can be used without value e.g. `grayscale`
+var flagOptions = []string{"grayscale", "invert"}
+
+// splitOptions splits options string at commas, values may contain commas too
+// e.g. `radius=10,20,30,40`: parts without "=" are joined to the previous option.
+// Flags without value are expanded e.g. `grayscale` to `grayscale=true`.
+func splitOptions(optionsStr string) []string {
+	options := make([]string, 0)
+	for _, part := range strings.Split(optionsStr, ",") {
+		if slices.Contains(flagOptions, strings.TrimSpace(part)) {
+			options = append(options, strings.TrimSpace(part)+"=true")
+			continue
+		}
+		if len(options) > 0 && !strings.Contains(part, "=") {
+			options[len(options)-1] += "," + part
+			continue
+		}
+		options = append(options, part)
+	}
+	return options
+}
+
+// sortOptions sorts options by name, keeping relative order of repeated options.
+func sortOptions(options []string) {
+	slices.SortStableFunc(options, func(a, b string) int {
+		keyA, _, _ := strings.Cut(a, "=")
+		keyB, _, _ := strings.Cut(b, "=")
+		return strings.Compare(keyA, keyB)
 	})
 }
 
@@ -89,13 +343,16 @@ func BindRouteTransformation(server *fiber.App, k *kritiimages.KritiImages) {
 //
 // return meaningful errors, they are sent as response as is
 func getContextFromString(optionsStr string) (map[kritiimages.TransformationOption]string, *kritiimages.DestinationImage, error) {
-	options := strings.Split(optionsStr, ",")
+	options := splitOptions(optionsStr)
 
 	destination := kritiimages.DestinationImage{
 		BgColor: color.Transparent,
 		Quality: 100,
 	}
 
+	dpr := 1.0
+	overlay, hasOverlayOptions := kritiimages.Overlay{Gravity: "center", Opacity: 1}, false
+	text, hasTextOptions := kritiimages.Text{Size: 32, Color: color.White, StrokeColor: color.Black, Gravity: "south"}, false
 	trValues := make(map[kritiimages.TransformationOption]string)
 	for _, optStr := range options {
 		transformation, values, err := processOption(optStr)
@@ -105,11 +362,19 @@ func getContextFromString(optionsStr string) (map[kritiimages.TransformationOpti
 
 		switch transformation {
 		case kritiimages.Background:
+			if values == "dominant" {
+				destination.BgDominant = true
+				continue
+			}
 			destination.BgColor, err = utils.ParseBackgroundColor(values)
 			if err != nil {
 				return nil, nil, fmt.Errorf("invalid background color: %w", err)
 			}
 		case kritiimages.Width:
+			if values == "auto" { // not resolved by client hints, source width is used
+				destination.Width = 0
+				continue
+			}
 			destination.Width, err = utils.ParseIntValue(values, 1, 10000)
 			if err != nil {
 				return nil, nil, fmt.Errorf("invalid width: %w", err)
@@ -129,11 +394,156 @@ func getContextFromString(optionsStr string) (map[kritiimages.TransformationOpti
 			if err != nil {
 				return nil, nil, fmt.Errorf("invalid quality: %w", err)
 			}
+		case kritiimages.Palette:
+			destination.Palette, err = utils.ParseIntValue(values, 1, 16)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid palette: %w", err)
+			}
+		case kritiimages.Mask:
+			destination.Mask, err = url.PathUnescape(values)
+			if err != nil || destination.Mask == "" {
+				return nil, nil, fmt.Errorf("invalid mask: %s", values)
+			}
+		case kritiimages.OverlayPath:
+			overlay.Path, err = url.PathUnescape(values)
+			if err != nil || overlay.Path == "" {
+				return nil, nil, fmt.Errorf("invalid overlay: %s", values)
+			}
+		case kritiimages.OverlayGravity:
+			hasOverlayOptions = true
+			overlay.Gravity, err = utils.ParseGravityValue(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid overlay_gravity: %w", err)
+			}
+		case kritiimages.OverlayX:
+			hasOverlayOptions = true
+			overlay.X, err = utils.ParseIntValue(values, -10000, 10000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid overlay_x: %w", err)
+			}
+		case kritiimages.OverlayY:
+			hasOverlayOptions = true
+			overlay.Y, err = utils.ParseIntValue(values, -10000, 10000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid overlay_y: %w", err)
+			}
+		case kritiimages.OverlayScale:
+			hasOverlayOptions = true
+			overlay.Scale = float64(utils.ParseFloatValue(values, 0.01, 1, 0))
+			if overlay.Scale == 0 {
+				return nil, nil, fmt.Errorf("invalid overlay_scale: value must be between 0.01 and 1, got %s", values)
+			}
+		case kritiimages.OverlayOpacity:
+			hasOverlayOptions = true
+			opacity, err := utils.ParseIntValue(values, 0, 100)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid overlay_opacity: %w", err)
+			}
+			overlay.Opacity = float64(opacity) / 100
+		case kritiimages.OverlayTile:
+			hasOverlayOptions = true
+			overlay.Tile, err = strconv.ParseBool(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid overlay_tile: value must be true or false, got %s", values)
+			}
+		case kritiimages.TextContent:
+			text.Content, err = url.PathUnescape(values)
+			if err != nil || strings.TrimSpace(text.Content) == "" {
+				return nil, nil, fmt.Errorf("invalid text: %s", values)
+			}
+		case kritiimages.TextFont:
+			hasTextOptions = true
+			text.Font = values
+		case kritiimages.TextSize:
+			hasTextOptions = true
+			text.Size, err = utils.ParseIntValue(values, 1, 500)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_size: %w", err)
+			}
+		case kritiimages.TextColor:
+			hasTextOptions = true
+			text.Color, err = utils.ParseBackgroundColor(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_color: %w", err)
+			}
+		case kritiimages.TextStroke:
+			hasTextOptions = true
+			text.Stroke, err = utils.ParseIntValue(values, 0, 10)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_stroke: %w", err)
+			}
+		case kritiimages.TextStrokeColor:
+			hasTextOptions = true
+			text.StrokeColor, err = utils.ParseBackgroundColor(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_stroke_color: %w", err)
+			}
+		case kritiimages.TextBackground:
+			hasTextOptions = true
+			text.Background, err = utils.ParseBackgroundColor(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_background: %w", err)
+			}
+		case kritiimages.TextPadding:
+			hasTextOptions = true
+			text.Padding, err = utils.ParseIntValue(values, 0, 1000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_padding: %w", err)
+			}
+		case kritiimages.TextGravity:
+			hasTextOptions = true
+			text.Gravity, err = utils.ParseGravityValue(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_gravity: %w", err)
+			}
+		case kritiimages.TextX:
+			hasTextOptions = true
+			text.X, err = utils.ParseIntValue(values, -10000, 10000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_x: %w", err)
+			}
+		case kritiimages.TextY:
+			hasTextOptions = true
+			text.Y, err = utils.ParseIntValue(values, -10000, 10000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_y: %w", err)
+			}
+		case kritiimages.TextWidth:
+			hasTextOptions = true
+			text.Width, err = utils.ParseIntValue(values, 1, 10000)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid text_width: %w", err)
+			}
+		case kritiimages.DPR:
+			dpr, err = utils.ParseDPRValue(values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid dpr: %w", err)
+			}
 		default:
 			trValues[transformation] = values
 		}
 	}
 
+	if overlay.Path != "" {
+		destination.Overlays = append(destination.Overlays, overlay)
+	} else if hasOverlayOptions {
+		return nil, nil, fmt.Errorf("overlay options require overlay image")
+	}
+	if text.Content != "" {
+		destination.Text = &text
+	} else if hasTextOptions {
+		return nil, nil, fmt.Errorf("text options require text")
+	}
+
+	// palette is sent as JSON unless an image format is requested for swatches
+	if destination.Palette > 0 && destination.Format == "" {
+		destination.Format = "json"
+	}
+
+	// dpr scales the requested dimensions, limited to the maximum dimension
+	destination.Width = min(int(math.Round(float64(destination.Width)*dpr)), 10000)
+	destination.Height = min(int(math.Round(float64(destination.Height)*dpr)), 10000)
+
 	return trValues, &destination, nil
 }
 
@@ -154,6 +564,8 @@ func processOption(optStr string) (kritiimages.TransformationOption, string, err
 		return kritiimages.Flip, value, nil
 	case "blur":
 		return kritiimages.Blur, value, nil
+	case "pixelate":
+		return kritiimages.Pixelate, value, nil
 	case "brightness":
 		return kritiimages.Brightness, value, nil
 	case "contrast":
@@ -180,6 +592,68 @@ func processOption(optStr string) (kritiimages.TransformationOption, string, err
 		return kritiimages.Quality, value, nil
 	case "radius":
 		return kritiimages.BorderRadius, value, nil
+	case "dpr":
+		return kritiimages.DPR, value, nil
+	case "palette":
+		return kritiimages.Palette, value, nil
+	case "mask":
+		return kritiimages.Mask, value, nil
+	case "border":
+		return kritiimages.Border, value, nil
+	case "padding":
+		return kritiimages.Padding, value, nil
+	case "shadow":
+		return kritiimages.Shadow, value, nil
+	case "grayscale":
+		return kritiimages.Grayscale, value, nil
+	case "sepia":
+		return kritiimages.Sepia, value, nil
+	case "invert":
+		return kritiimages.Invert, value, nil
+	case "hue":
+		return kritiimages.Hue, value, nil
+	case "colorize":
+		return kritiimages.Colorize, value, nil
+	case "duotone":
+		return kritiimages.Duotone, value, nil
+	case "overlay":
+		return kritiimages.OverlayPath, value, nil
+	case "overlay_gravity":
+		return kritiimages.OverlayGravity, value, nil
+	case "overlay_x":
+		return kritiimages.OverlayX, value, nil
+	case "overlay_y":
+		return kritiimages.OverlayY, value, nil
+	case "overlay_scale":
+		return kritiimages.OverlayScale, value, nil
+	case "overlay_opacity":
+		return kritiimages.OverlayOpacity, value, nil
+	case "overlay_tile":
+		return kritiimages.OverlayTile, value, nil
+	case "text":
+		return kritiimages.TextContent, value, nil
+	case "text_font":
+		return kritiimages.TextFont, value, nil
+	case "text_size":
+		return kritiimages.TextSize, value, nil
+	case "text_color":
+		return kritiimages.TextColor, value, nil
+	case "text_stroke":
+		return kritiimages.TextStroke, value, nil
+	case "text_stroke_color":
+		return kritiimages.TextStrokeColor, value, nil
+	case "text_background":
+		return kritiimages.TextBackground, value, nil
+	case "text_padding":
+		return kritiimages.TextPadding, value, nil
+	case "text_gravity":
+		return kritiimages.TextGravity, value, nil
+	case "text_x":
+		return kritiimages.TextX, value, nil
+	case "text_y":
+		return kritiimages.TextY, value, nil
+	case "text_width":
+		return kritiimages.TextWidth, value, nil
 	default:
 		return -1, "", fmt.Errorf("unknown option: %s", key)
 	}
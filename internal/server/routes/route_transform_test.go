@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kritihq/kriti-images/internal/cdn"
+	"github.com/kritihq/kriti-images/internal/config"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+func TestCanonicalOptions(t *testing.T) {
+	presets := map[string]string{
+		"thumb": "width=200,height=200,format=webp",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{
+			name:     "sorted by option name",
+			input:    "width=100,blur=5",
+			expected: "blur=5,width=100",
+		},
+		{
+			name:     "preset expanded",
+			input:    "preset=thumb",
+			expected: "format=webp,height=200,width=200",
+		},
+		{
+			name:     "option after preset overrides it",
+			input:    "preset=thumb,format=png",
+			expected: "format=webp,format=png,height=200,width=200",
+		},
+		{
+			name:     "values with commas kept together",
+			input:    "width=100,radius=10,20/5,30,blur=5",
+			expected: "blur=5,radius=10,20/5,30,width=100",
+		},
+		{
+			name:     "regions kept together",
+			input:    "pixelate=16@10,10,50,50@100,40,30,30,blur=5",
+			expected: "blur=5,pixelate=16@10,10,50,50@100,40,30,30",
+		},
+		{
+			name:     "flags without value enabled",
+			input:    "width=100,grayscale,invert=false",
+			expected: "grayscale=true,invert=false,width=100",
+		},
+		{
+			name:     "unknown preset",
+			input:    "preset=banner",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := canonicalOptions(tt.input, presets)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	etag := `"abc"`
+	modTime := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		notModified bool
+	}{
+		{
+			name:        "unconditional request",
+			headers:     map[string]string{},
+			notModified: false,
+		},
+		{
+			name:        "matching etag",
+			headers:     map[string]string{"If-None-Match": `"xyz", "abc"`},
+			notModified: true,
+		},
+		{
+			name:        "weak matching etag",
+			headers:     map[string]string{"If-None-Match": `W/"abc"`},
+			notModified: true,
+		},
+		{
+			name:        "different etag ignores modified since",
+			headers:     map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": modTime.Format(http.TimeFormat)},
+			notModified: false,
+		},
+		{
+			name:        "not modified since",
+			headers:     map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)},
+			notModified: true,
+		},
+		{
+			name:        "modified since",
+			headers:     map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			notModified: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if got := isNotModified(c, etag, modTime); got != tt.notModified {
+					t.Errorf("Expected notModified=%v, got %v", tt.notModified, got)
+				}
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetContextFromStringJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		format  string
+		palette int
+	}{
+		{name: "image info", options: "format=json", format: "json"},
+		{name: "palette as json by default", options: "palette=5", format: "json", palette: 5},
+		{name: "palette as explicit json", options: "palette=5,format=json", format: "json", palette: 5},
+		{name: "palette as swatch image", options: "palette=5,format=png", format: "png", palette: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, dest, err := getContextFromString(tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if dest.Format != tt.format || dest.Palette != tt.palette {
+				t.Errorf("Expected format %q and palette %d, got %q and %d", tt.format, tt.palette, dest.Format, dest.Palette)
+			}
+		})
+	}
+}
+
+func TestTransformImagePathSpellings(t *testing.T) {
+	basePath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(basePath, "a.png"), testPNG(t, 4), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source := kritiimages.NewImageSourceLocal(basePath, &imagesources.SourceImageValidations{MaxImageDimension: 100, MaxFileSizeInBytes: 1024 * 1024})
+
+	app := fiber.New()
+	BindRouteTransformation(app, kritiimages.New(map[string]kritiimages.ImageSource{"local": source}, source), &config.ImagesConfig{Source: "local"}, nil)
+
+	// purges after upload use the stored filename
+	key := cdn.SurrogateKey("a.png")
+	var etag string
+	for _, path := range []string{"a.png", ".%2Fa.png", "x%2F..%2Fa.png"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/cgi/images/tr:width=2/"+path, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Surrogate-Key"); got != key {
+				t.Errorf("Expected surrogate key %s, got %s", key, got)
+			}
+			if etag == "" {
+				if etag = resp.Header.Get(fiber.HeaderETag); etag == "" {
+					t.Fatal("Expected ETag of the variant")
+				}
+			} else if got := resp.Header.Get(fiber.HeaderETag); got != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// package templates loads YAML templates rendered by kritiimages.KritiImages.Render,
+// one template per `<name>.yaml` file in a directory.
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/kritihq/kriti-images/internal/utils"
+	"github.com/kritihq/kriti-images/pkg/kritiimages"
+)
+
+// maxCanvasDimension limits width and height of templates, same as transformations
+const maxCanvasDimension = 10000
+
+// templateConfig is the YAML definition of a template
+type templateConfig struct {
+	Width           int           `mapstructure:"width"`
+	Height          int           `mapstructure:"height"`
+	Background      string        `mapstructure:"background"`       // canvas color, transparent by default
+	BackgroundImage string        `mapstructure:"background_image"` // image path covering the canvas
+	Format          string        `mapstructure:"format"`           // png by default
+	Quality         int           `mapstructure:"quality"`          // 1 to 100, 100 by default
+	Layers          []layerConfig `mapstructure:"layers"`
+}
+
+// layerConfig is an image or text layer, fields not applicable to its type are ignored
+type layerConfig struct {
+	Type string `mapstructure:"type"` // image or text
+	Slot string `mapstructure:"slot"` // query parameter replacing path or text
+
+	// position and size of the image, position of the text
+	X      int `mapstructure:"x"`
+	Y      int `mapstructure:"y"`
+	Width  int `mapstructure:"width"` // text is wrapped at width
+	Height int `mapstructure:"height"`
+
+	// image
+	Path string `mapstructure:"path"`
+	Fit  string `mapstructure:"fit"` // cover by default
+
+	// text, see `text` transformation
+	Text        string `mapstructure:"text"`
+	Font        string `mapstructure:"font"`
+	Size        int    `mapstructure:"size"`
+	Color       string `mapstructure:"color"`
+	Stroke      int    `mapstructure:"stroke"`
+	StrokeColor string `mapstructure:"stroke_color"`
+	Background  string `mapstructure:"background"`
+	Padding     int    `mapstructure:"padding"`
+	Gravity     string `mapstructure:"gravity"` // northwest by default, x and y are offsets from its edges
+}
+
+// Load reads all `*.yaml` and `*.yml` files of `dir` as templates keyed by
+// file name without extension. No templates are loaded when `dir` is empty.
+func Load(dir string) (map[string]*kritiimages.Template, error) {
+	templates := make(map[string]*kritiimages.Template)
+	if dir == "" {
+		return templates, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		template, err := load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", name, err)
+		}
+		templates[name] = template
+	}
+	return templates, nil
+}
+
+func load(path string) (*kritiimages.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	var cfg templateConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+
+	template, err := parse(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	template.Digest = hex.EncodeToString(sum[:16])
+	template.ModTime = info.ModTime()
+	return template, nil
+}
+
+// parse validates the template definition and converts it to kritiimages.Template
+func parse(cfg *templateConfig) (*kritiimages.Template, error) {
+	if cfg.Width < 1 || cfg.Width > maxCanvasDimension || cfg.Height < 1 || cfg.Height > maxCanvasDimension {
+		return nil, fmt.Errorf("width and height must be between 1 and %d", maxCanvasDimension)
+	}
+
+	template := &kritiimages.Template{
+		Width:           cfg.Width,
+		Height:          cfg.Height,
+		BackgroundImage: cfg.BackgroundImage,
+		Quality:         cfg.Quality,
+		Layers:          make([]kritiimages.TemplateLayer, 0, len(cfg.Layers)),
+	}
+	var err error
+	if template.Background, err = parseColor(cfg.Background, nil); err != nil {
+		return nil, fmt.Errorf("invalid background: %w", err)
+	}
+	if cfg.Format != "" {
+		if template.Format, err = utils.ParseFormatValue(cfg.Format); err != nil {
+			return nil, fmt.Errorf("invalid format: %w", err)
+		}
+	}
+	if cfg.Quality < 0 || cfg.Quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	for i, layerCfg := range cfg.Layers {
+		layer, err := parseLayer(&layerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer %d: %w", i+1, err)
+		}
+		template.Layers = append(template.Layers, *layer)
+	}
+	return template, nil
+}
+
+func parseLayer(cfg *layerConfig) (*kritiimages.TemplateLayer, error) {
+	layer := &kritiimages.TemplateLayer{Slot: cfg.Slot}
+
+	switch cfg.Type {
+	case "image":
+		if cfg.Width < 1 || cfg.Height < 1 {
+			return nil, fmt.Errorf("width and height of image are required")
+		}
+		if cfg.Path == "" && cfg.Slot == "" {
+			return nil, fmt.Errorf("path or slot of image is required")
+		}
+		layer.Image = &kritiimages.TemplateImage{
+			Path:   cfg.Path,
+			Fit:    cfg.Fit,
+			X:      cfg.X,
+			Y:      cfg.Y,
+			Width:  cfg.Width,
+			Height: cfg.Height,
+		}
+	case "text":
+		if cfg.Text == "" && cfg.Slot == "" {
+			return nil, fmt.Errorf("text or slot of text is required")
+		}
+		if cfg.Gravity == "" {
+			cfg.Gravity = "northwest"
+		}
+		if cfg.Size == 0 {
+			cfg.Size = 32
+		}
+		if cfg.Size < 1 || cfg.Size > 500 || cfg.Stroke < 0 || cfg.Stroke > 10 {
+			return nil, fmt.Errorf("size must be between 1 and 500 and stroke between 0 and 10")
+		}
+
+		text := &kritiimages.Text{
+			Content: cfg.Text,
+			Font:    cfg.Font,
+			Size:    cfg.Size,
+			Stroke:  cfg.Stroke,
+			Padding: cfg.Padding,
+			X:       cfg.X,
+			Y:       cfg.Y,
+			Width:   cfg.Width,
+		}
+		var err error
+		if text.Gravity, err = utils.ParseGravityValue(cfg.Gravity); err != nil {
+			return nil, fmt.Errorf("invalid gravity: %w", err)
+		}
+		if text.Color, err = parseColor(cfg.Color, color.White); err != nil {
+			return nil, fmt.Errorf("invalid color: %w", err)
+		}
+		if text.StrokeColor, err = parseColor(cfg.StrokeColor, color.Black); err != nil {
+			return nil, fmt.Errorf("invalid stroke_color: %w", err)
+		}
+		if text.Background, err = parseColor(cfg.Background, nil); err != nil {
+			return nil, fmt.Errorf("invalid background: %w", err)
+		}
+		layer.Text = text
+	default:
+		return nil, fmt.Errorf("type must be image or text, got %q", cfg.Type)
+	}
+	return layer, nil
+}
+
+// parseColor returns `defaultColor` when `value` is empty
+func parseColor(value string, defaultColor color.Color) (color.Color, error) {
+	if value == "" {
+		return defaultColor, nil
+	}
+	return utils.ParseBackgroundColor(value)
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		hasError bool
+	}{
+		{
+			name: "image and text layers",
+			template: `
+width: 1200
+height: 630
+background: "#0f172a"
+layers:
+  - type: image
+    slot: image
+    x: 60
+    y: 60
+    width: 510
+    height: 510
+  - type: text
+    slot: title
+    text: Untitled
+    font: gobold
+    size: 64
+    gravity: ne
+`,
+		},
+		{
+			name:     "missing dimensions",
+			template: "layers: []",
+			hasError: true,
+		},
+		{
+			name: "unknown layer type",
+			template: `
+width: 100
+height: 100
+layers:
+  - type: video
+`,
+			hasError: true,
+		},
+		{
+			name: "image without path and slot",
+			template: `
+width: 100
+height: 100
+layers:
+  - type: image
+    width: 10
+    height: 10
+`,
+			hasError: true,
+		},
+		{
+			name: "invalid text color",
+			template: `
+width: 100
+height: 100
+layers:
+  - type: text
+    text: hi
+    color: notacolor
+`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "card.yaml"), []byte(tt.template), 0o644); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a template"), 0o644); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			templates, err := Load(dir)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			template, ok := templates["card"]
+			if len(templates) != 1 || !ok {
+				t.Fatalf("Expected only card template, got %v", templates)
+			}
+			if len(template.Layers) != 2 || template.Layers[0].Image == nil || template.Layers[1].Text == nil {
+				t.Fatalf("Expected image and text layers, got %+v", template.Layers)
+			}
+			if text := template.Layers[1].Text; text.Gravity != "northeast" || text.Size != 64 || text.Content != "Untitled" {
+				t.Errorf("Expected text layer northeast of size 64, got %+v", text)
+			}
+			if slots := template.Slots(); len(slots) != 2 || slots[0] != "image" || slots[1] != "title" {
+				t.Errorf("Expected slots [image title], got %v", slots)
+			}
+			if template.Digest == "" {
+				t.Errorf("Expected digest of template")
+			}
+		})
+	}
+}
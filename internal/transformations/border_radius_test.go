@@ -1,6 +1,9 @@
 package transformations
 
 import (
+	"image"
+	"image/color"
+	"image/draw"
 	"testing"
 )
 
@@ -20,6 +23,31 @@ func TestCreateBorderRadiusFilter(t *testing.T) {
 			input:    "25%",
 			hasError: false,
 		},
+		{
+			name:     "valid per corner values",
+			input:    "10,20,30,40",
+			hasError: false,
+		},
+		{
+			name:     "valid elliptical value",
+			input:    "10/20",
+			hasError: false,
+		},
+		{
+			name:     "valid escaped elliptical value",
+			input:    "50%25%2F20%25",
+			hasError: false,
+		},
+		{
+			name:     "too many values",
+			input:    "1,2,3,4,5",
+			hasError: true,
+		},
+		{
+			name:     "invalid vertical value",
+			input:    "10/",
+			hasError: true,
+		},
 		{
 			name:     "invalid value",
 			input:    "invalid",
@@ -57,3 +85,56 @@ func TestCreateBorderRadiusFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestBorderRadiusFilter(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name        string
+		input       string
+		transparent []image.Point
+		opaque      []image.Point
+	}{
+		{
+			name:        "only top-left corner",
+			input:       "10,0,0,0",
+			transparent: []image.Point{{0, 0}},
+			opaque:      []image.Point{{39, 0}, {39, 19}, {0, 19}, {5, 5}},
+		},
+		{
+			name:        "large radius scaled to shorter side",
+			input:       "100",
+			transparent: []image.Point{{0, 0}, {39, 0}, {1, 3}},
+			opaque:      []image.Point{{20, 0}, {1, 10}, {38, 10}},
+		},
+		{
+			name:        "elliptical corners",
+			input:       "20/10",
+			transparent: []image.Point{{2, 2}},
+			opaque:      []image.Point{{20, 2}, {2, 10}, {10, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := CreateBorderRadiusFilter(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			dst := image.NewRGBA(filter.Bounds(src.Bounds()))
+			filter.Draw(dst, src, nil)
+			for _, point := range tt.transparent {
+				if a := dst.RGBAAt(point.X, point.Y).A; a != 0 {
+					t.Errorf("Expected transparent pixel at %v, got alpha %d", point, a)
+				}
+			}
+			for _, point := range tt.opaque {
+				if a := dst.RGBAAt(point.X, point.Y).A; a != 255 {
+					t.Errorf("Expected opaque pixel at %v, got alpha %d", point, a)
+				}
+			}
+		})
+	}
+}
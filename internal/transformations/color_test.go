@@ -0,0 +1,66 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDuotoneFilter(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	src.SetRGBA(0, 0, color.RGBA{0, 0, 0, 255})
+	src.SetRGBA(1, 0, color.RGBA{255, 255, 255, 255})
+	src.SetRGBA(2, 0, color.RGBA{128, 128, 128, 255})
+
+	filter, err := CreateDuotoneFilter("%23000080,%23ff8000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dst := image.NewRGBA(filter.Bounds(src.Bounds()))
+	filter.Draw(dst, src, nil)
+
+	expected := []color.RGBA{{0, 0, 128, 255}, {255, 128, 0, 255}, {128, 64, 64, 255}}
+	for x, want := range expected {
+		if got := dst.RGBAAt(x, 0); got != want {
+			t.Errorf("Expected %v at %d, got %v", want, x, got)
+		}
+	}
+}
+
+func TestHueSaturation(t *testing.T) {
+	tests := []struct {
+		name       string
+		color      color.Color
+		hue        float32
+		saturation float32
+	}{
+		{name: "red", color: color.RGBA{255, 0, 0, 255}, hue: 0, saturation: 100},
+		{name: "blue", color: color.RGBA{0, 0, 255, 255}, hue: 240, saturation: 100},
+		{name: "magenta", color: color.RGBA{255, 0, 255, 255}, hue: 300, saturation: 100},
+		{name: "gray", color: color.RGBA{128, 128, 128, 255}, hue: 0, saturation: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hue, saturation := hueSaturation(tt.color)
+			if hue != tt.hue || saturation != tt.saturation {
+				t.Errorf("Expected hue %v and saturation %v, got %v and %v", tt.hue, tt.saturation, hue, saturation)
+			}
+		})
+	}
+}
+
+func TestCreateColorFilters(t *testing.T) {
+	if _, err := CreateColorizeFilter("%230055ff,60"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := CreateColorizeFilter("%230055ff,160"); err == nil {
+		t.Errorf("Expected error for percentage over 100")
+	}
+	if _, err := CreateDuotoneFilter("%23000000"); err == nil {
+		t.Errorf("Expected error for duotone without light color")
+	}
+	if _, err := CreateDuotoneFilter("notacolor,white"); err == nil {
+		t.Errorf("Expected error for invalid duotone color")
+	}
+}
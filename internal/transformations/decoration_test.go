@@ -0,0 +1,124 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/disintegration/gift"
+)
+
+func TestDecorationFilters(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(src, src.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	rounded := image.NewRGBA(src.Bounds())
+	radius, _ := CreateBorderRadiusFilter("5")
+	radius.Draw(rounded, src, nil)
+
+	tests := []struct {
+		name   string
+		create func() (gift.Filter, error)
+		src    image.Image
+		bounds image.Rectangle
+		pixels map[image.Point]color.RGBA
+	}{
+		{
+			name:   "padding with background",
+			create: func() (gift.Filter, error) { return CreatePaddingFilter("1,2,3,4", color.White) },
+			src:    src,
+			bounds: image.Rect(0, 0, 26, 14),
+			pixels: map[image.Point]color.RGBA{{0, 0}: {255, 255, 255, 255}, {4, 1}: red, {23, 10}: red, {24, 10}: {255, 255, 255, 255}, {4, 11}: {255, 255, 255, 255}},
+		},
+		{
+			name:   "border of rectangle",
+			create: func() (gift.Filter, error) { return CreateBorderFilter("2,%230000ff") },
+			src:    src,
+			bounds: image.Rect(0, 0, 24, 14),
+			pixels: map[image.Point]color.RGBA{{0, 0}: {0, 0, 255, 255}, {2, 2}: red, {23, 13}: {0, 0, 255, 255}},
+		},
+		{
+			name:   "border follows rounded corners",
+			create: func() (gift.Filter, error) { return CreateBorderFilter("2,%230000ff") },
+			src:    rounded,
+			bounds: image.Rect(0, 0, 24, 14),
+			pixels: map[image.Point]color.RGBA{{0, 0}: {}, {12, 0}: {0, 0, 255, 255}, {12, 7}: red, {1, 7}: {0, 0, 255, 255}},
+		},
+		{
+			name:   "shadow offset without blur",
+			create: func() (gift.Filter, error) { return CreateShadowFilter("0,3,black") },
+			src:    src,
+			bounds: image.Rect(0, 0, 20, 13),
+			pixels: map[image.Point]color.RGBA{{0, 0}: red, {0, 12}: {0, 0, 0, 255}, {19, 9}: red},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := tt.create()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			bounds := filter.Bounds(tt.src.Bounds())
+			if bounds != tt.bounds {
+				t.Fatalf("Expected bounds %v, got %v", tt.bounds, bounds)
+			}
+			dst := image.NewRGBA(bounds)
+			filter.Draw(dst, tt.src, nil)
+			for point, expected := range tt.pixels {
+				if got := dst.RGBAAt(point.X, point.Y); got != expected {
+					t.Errorf("Expected %v at %v, got %v", expected, point, got)
+				}
+			}
+		})
+	}
+}
+
+func TestShadowBounds(t *testing.T) {
+	filter, err := CreateShadowFilter("2,-2:4")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 6px spread, shifted 2px left and 4px down
+	if bounds := filter.Bounds(image.Rect(0, 0, 10, 10)); bounds != image.Rect(0, 0, 10+4+8, 10+2+10) {
+		t.Errorf("Expected bounds %v, got %v", image.Rect(0, 0, 22, 22), bounds)
+	}
+}
+
+func TestDistanceTransform(t *testing.T) {
+	pixels := make([]bool, 25)
+	pixels[12] = true // center of 5x5
+
+	distances := distanceTransform(pixels, 5, 5)
+	expected := map[int]float64{12: 0, 13: 1, 2: 2, 0: 2.8284271247461903}
+	for i, distance := range expected {
+		if distances[i] != distance {
+			t.Errorf("Expected distance %v at %d, got %v", distance, i, distances[i])
+		}
+	}
+}
+
+func TestCreateDecorationFilters(t *testing.T) {
+	invalid := []struct {
+		name   string
+		create func() (gift.Filter, error)
+	}{
+		{"padding with too many values", func() (gift.Filter, error) { return CreatePaddingFilter("1,2,3,4,5", nil) }},
+		{"negative padding", func() (gift.Filter, error) { return CreatePaddingFilter("-1", nil) }},
+		{"border without width", func() (gift.Filter, error) { return CreateBorderFilter(",red") }},
+		{"border with invalid color", func() (gift.Filter, error) { return CreateBorderFilter("2,notacolor") }},
+		{"shadow with invalid blur", func() (gift.Filter, error) { return CreateShadowFilter("a") }},
+		{"shadow with invalid offset", func() (gift.Filter, error) { return CreateShadowFilter("2,1:a") }},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.create(); err == nil {
+				t.Errorf("Expected error but got none")
+			}
+		})
+	}
+}
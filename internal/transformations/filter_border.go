@@ -0,0 +1,138 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/imagemeta"
+	"github.com/kritihq/kriti-images/internal/utils"
+)
+
+// CreateBorderFilter creates a filter drawing a border of `value` i.e.
+// "<width>,<color>" around the image. Border follows the shape of opaque pixels,
+// so that rounded or masked images get rounded borders.
+func CreateBorderFilter(value string) (gift.Filter, error) {
+	width, borderColor, err := utils.ParseBorderValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &borderFilter{width: width, color: borderColor}, nil
+}
+
+// borderFilter extends bounds of the image by width of the border on each side
+type borderFilter struct {
+	width int
+	color color.Color
+}
+
+func (f *borderFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return image.Rect(0, 0, srcBounds.Dx()+2*f.width, srcBounds.Dy()+2*f.width)
+}
+
+func (f *borderFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	srcBounds := src.Bounds()
+	target := image.Rectangle{Min: bounds.Min.Add(image.Pt(f.width, f.width))}
+	target.Max = target.Min.Add(srcBounds.Size())
+
+	if !imagemeta.HasAlpha(src) {
+		// rectangular image, border is a filled rectangle
+		draw.Draw(dst, bounds, image.NewUniform(f.color), image.Point{}, draw.Src)
+		draw.Draw(dst, target, src, srcBounds.Min, draw.Src)
+		return
+	}
+
+	// border covers pixels within width from opaque pixels of the source
+	distances := distanceTransform(opaquePixels(src, f.width), bounds.Dx(), bounds.Dy())
+	mask := image.NewAlpha(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			// pixels at distance of width are covered, farther ones are anti-aliased
+			coverage := math.Max(0, math.Min(1, float64(f.width)+1-distances[y*bounds.Dx()+x]))
+			mask.SetAlpha(bounds.Min.X+x, bounds.Min.Y+y, color.Alpha{uint8(coverage * 255)})
+		}
+	}
+
+	draw.DrawMask(dst, bounds, image.NewUniform(f.color), image.Point{}, mask, bounds.Min, draw.Src)
+	draw.Draw(dst, target, src, srcBounds.Min, draw.Over)
+}
+
+// opaquePixels returns pixels of the source with alpha of at least 50%, in a
+// grid larger by `margin` on each side, row by row.
+func opaquePixels(src image.Image, margin int) []bool {
+	srcBounds := src.Bounds()
+	width := srcBounds.Dx() + 2*margin
+	opaque := make([]bool, width*(srcBounds.Dy()+2*margin))
+	for y := srcBounds.Min.Y; y < srcBounds.Max.Y; y++ {
+		for x := srcBounds.Min.X; x < srcBounds.Max.X; x++ {
+			if _, _, _, a := src.At(x, y).RGBA(); a >= 0x8000 {
+				opaque[(y-srcBounds.Min.Y+margin)*width+x-srcBounds.Min.X+margin] = true
+			}
+		}
+	}
+	return opaque
+}
+
+// distanceTransform returns euclidean distance of each pixel from the nearest
+// pixel set in `pixels`, using the linear time algorithm of Felzenszwalb and
+// Huttenlocher applied to columns and then rows.
+func distanceTransform(pixels []bool, width, height int) []float64 {
+	inf := float64(width*width + height*height)
+	squared := make([]float64, len(pixels))
+	for i, set := range pixels {
+		if !set {
+			squared[i] = inf
+		}
+	}
+
+	column := make([]float64, height)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			column[y] = squared[y*width+x]
+		}
+		column = distanceTransform1D(column)
+		for y := 0; y < height; y++ {
+			squared[y*width+x] = column[y]
+		}
+	}
+	for y := 0; y < height; y++ {
+		copy(squared[y*width:(y+1)*width], distanceTransform1D(squared[y*width:(y+1)*width]))
+	}
+
+	for i := range squared {
+		squared[i] = math.Sqrt(squared[i])
+	}
+	return squared
+}
+
+// distanceTransform1D returns squared distance transform of sampled function `f`
+func distanceTransform1D(f []float64) []float64 {
+	n := len(f)
+	d := make([]float64, n)
+	v := make([]int, n)       // locations of parabolas in lower envelope
+	z := make([]float64, n+1) // boundaries between parabolas
+	k := 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+	return d
+}
@@ -11,7 +11,8 @@ import (
 	"github.com/kritihq/kriti-images/internal/utils"
 )
 
-// createBorderRadiusFilter creates a filter that applies rounded corners to an image
+// createBorderRadiusFilter creates a filter that applies rounded corners to an image,
+// `value` is CSS border-radius like e.g. "10", "10,20,30,40" or "50%/20%", see utils.ParseBorderRadii
 func CreateBorderRadiusFilter(value string) (gift.Filter, error) {
 	// Validate that value is not empty
 	if value == "" {
@@ -19,162 +20,137 @@ func CreateBorderRadiusFilter(value string) (gift.Filter, error) {
 	}
 
 	// Parse the border radius value with proper validation
-	radii, err := utils.ParseBorderRadiusValue(value)
+	radii, err := utils.ParseBorderRadii(value)
 	if err != nil {
 		return nil, err
 	}
 
 	return &borderRadiusFilter{
-		tl: radii,
-		tr: radii,
-		bl: radii,
-		br: radii,
+		tl: cornerRadius{x: radii.Horizontal[0], y: radii.Vertical[0]},
+		tr: cornerRadius{x: radii.Horizontal[1], y: radii.Vertical[1]},
+		br: cornerRadius{x: radii.Horizontal[2], y: radii.Vertical[2]},
+		bl: cornerRadius{x: radii.Horizontal[3], y: radii.Vertical[3]},
 	}, nil
 }
 
+// cornerRadius holds horizontal and vertical radius of a corner, equal for circular corners
+type cornerRadius struct {
+	x, y utils.BorderRadiusValue
+}
+
 // borderRadiusFilter applies rounded corners to an image
 type borderRadiusFilter struct {
-	tl *utils.BorderRadiusValue // top-left in px
-	tr *utils.BorderRadiusValue // top-right
-	bl *utils.BorderRadiusValue // bottom-left
-	br *utils.BorderRadiusValue // bottom-right
+	tl cornerRadius // top-left
+	tr cornerRadius // top-right
+	bl cornerRadius // bottom-left
+	br cornerRadius // bottom-right
 }
 
+// cornerRadii holds radii of corners in px, in order top-left, top-right,
+// bottom-right and bottom-left; horizontal radius first.
+type cornerRadii [4][2]float64
+
 func (f *borderRadiusFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
 	return srcBounds
 }
 
 func (f *borderRadiusFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
-	bounds := src.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-
-	maxRadius := float32(math.Min(float64(width), float64(height))) / 2
-	minDim := float32(math.Min(float64(width), float64(height)))
-	if f.tl.IsPercent {
-		f.tl.Value = (f.tl.Value / 100.0) * minDim // convert to px
-		f.tl.IsPercent = false
-	}
-	f.tl.Value = float32(math.Min(float64(f.tl.Value), float64(maxRadius))) // clamp to max
-	if f.tr.IsPercent {
-		f.tr.Value = (f.tr.Value / 100.0) * minDim
-		f.tr.IsPercent = false
-	}
-	f.tr.Value = float32(math.Min(float64(f.tr.Value), float64(maxRadius)))
-	if f.bl.IsPercent {
-		f.bl.Value = (f.bl.Value / 100.0) * minDim
-		f.bl.IsPercent = false
-	}
-	f.bl.Value = float32(math.Min(float64(f.bl.Value), float64(maxRadius)))
-	if f.br.IsPercent {
-		f.br.Value = (f.br.Value / 100.0) * minDim
-		f.br.IsPercent = false
-	}
-	f.br.Value = float32(math.Min(float64(f.br.Value), float64(maxRadius)))
+	bounds := dst.Bounds()
+	radii := f.resolve(bounds.Dx(), bounds.Dy())
 
 	// Create a mask for the rounded rectangle
 	mask := image.NewAlpha(bounds)
-
-	// Fill the mask with the rounded rectangle shape
-	f.drawRoundedRectMask(mask, bounds)
-
-	// Apply the source image with the mask
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			// Get the alpha value from our mask
-			maskAlpha := mask.AlphaAt(x, y).A
-
-			if maskAlpha > 0 {
-				// Get the source pixel
-				srcColor := src.At(x, y)
-
-				// If the mask is partially transparent, blend with transparency
-				if maskAlpha < 255 {
-					r, g, b, a := srcColor.RGBA()
-					// Apply mask alpha
-					newAlpha := uint8((uint32(maskAlpha) * (a >> 8)) >> 8)
-					dst.Set(x, y, color.RGBA{
-						uint8(r >> 8),
-						uint8(g >> 8),
-						uint8(b >> 8),
-						newAlpha,
-					})
-				} else {
-					dst.Set(x, y, srcColor)
-				}
-			} else {
-				// Outside the rounded rectangle, set to transparent
-				dst.Set(x, y, color.RGBA{0, 0, 0, 0})
-			}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			mask.SetAlpha(bounds.Min.X+x, bounds.Min.Y+y, color.Alpha{radii.alpha(x, y, bounds.Dx(), bounds.Dy())})
 		}
 	}
+
+	// Apply the source image with the mask, outside the rounded rectangle is transparent
+	draw.DrawMask(dst, bounds, src, src.Bounds().Min, mask, bounds.Min, draw.Src)
 }
 
-// drawRoundedRectMask draws a rounded rectangle mask
-func (f *borderRadiusFilter) drawRoundedRectMask(mask *image.Alpha, bounds image.Rectangle) {
-	width := bounds.Dx()
-	height := bounds.Dy()
+// resolve converts radii to px, percentages are of the shorter side. Radii are
+// scaled down when adjacent corners overlap, same as CSS, so that e.g. large
+// radius of a non-square image results in semicircular sides.
+func (f *borderRadiusFilter) resolve(width, height int) cornerRadii {
+	minDim := math.Min(float64(width), float64(height))
+	toPx := func(value utils.BorderRadiusValue) float64 {
+		if value.IsPercent {
+			return float64(value.Value) / 100.0 * minDim
+		}
+		return float64(value.Value)
+	}
+
+	radii := cornerRadii{}
+	for i, corner := range []cornerRadius{f.tl, f.tr, f.br, f.bl} {
+		radii[i] = [2]float64{toPx(corner.x), toPx(corner.y)}
+	}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			alpha := f.calculatePixelAlpha(x, y, width, height)
-			mask.SetAlpha(x, y, color.Alpha{alpha})
+	// sides in order top, right, bottom, left with sum of radii along them
+	scale := 1.0
+	for _, side := range []struct {
+		length float64
+		sum    float64
+	}{
+		{float64(width), radii[0][0] + radii[1][0]},
+		{float64(height), radii[1][1] + radii[2][1]},
+		{float64(width), radii[2][0] + radii[3][0]},
+		{float64(height), radii[3][1] + radii[0][1]},
+	} {
+		if side.sum > 0 {
+			scale = math.Min(scale, side.length/side.sum)
 		}
 	}
+	for i := range radii {
+		radii[i][0] *= scale
+		radii[i][1] *= scale
+	}
+	return radii
 }
 
-// calculatePixelAlpha determines the alpha value for a pixel in the rounded rectangle
-func (f *borderRadiusFilter) calculatePixelAlpha(x, y, width, height int) uint8 {
-	fx := float32(x)
-	fy := float32(y)
-	fw := float32(width)
-	fh := float32(height)
+// alpha determines the alpha value for a pixel in the rounded rectangle
+func (radii cornerRadii) alpha(x, y, width, height int) uint8 {
+	// pixel center, so that opposite edges are symmetric
+	fx, fy := float64(x)+0.5, float64(y)+0.5
+	fw, fh := float64(width), float64(height)
 
 	// Determine which corner region we're in
-	var radius float32
-	var centerX, centerY float32
-
-	// Top-left corner
-	if fx < f.tl.Value && fy < f.tl.Value {
-		radius = f.tl.Value
-		centerX = f.tl.Value
-		centerY = f.tl.Value
-	} else if fx >= fw-f.tr.Value && fy < f.tr.Value {
-		// Top-right corner
-		radius = f.tr.Value
-		centerX = fw - f.tr.Value
-		centerY = f.tr.Value
-	} else if fx >= fw-f.br.Value && fy >= fh-f.br.Value {
-		// Bottom-right corner
-		radius = f.br.Value
-		centerX = fw - f.br.Value
-		centerY = fh - f.br.Value
-	} else if fx < f.bl.Value && fy >= fh-f.bl.Value {
-		// Bottom-left corner
-		radius = f.bl.Value
-		centerX = f.bl.Value
-		centerY = fh - f.bl.Value
-	} else {
+	var rx, ry, centerX, centerY float64
+	switch tl, tr, br, bl := radii[0], radii[1], radii[2], radii[3]; {
+	case fx < tl[0] && fy < tl[1]:
+		rx, ry, centerX, centerY = tl[0], tl[1], tl[0], tl[1]
+	case fx >= fw-tr[0] && fy < tr[1]:
+		rx, ry, centerX, centerY = tr[0], tr[1], fw-tr[0], tr[1]
+	case fx >= fw-br[0] && fy >= fh-br[1]:
+		rx, ry, centerX, centerY = br[0], br[1], fw-br[0], fh-br[1]
+	case fx < bl[0] && fy >= fh-bl[1]:
+		rx, ry, centerX, centerY = bl[0], bl[1], bl[0], fh-bl[1]
+	default:
 		// Not in a corner region, fully opaque
 		return 255
 	}
 
-	// Calculate distance from corner center
-	dx := fx - centerX
-	dy := fy - centerY
-	distance := float32(math.Sqrt(float64(dx*dx + dy*dy)))
-
-	// If we're inside the radius, it's opaque
-	if distance <= radius {
-		// Add some anti-aliasing for smoother edges
-		if distance >= radius-1 {
-			// Linear interpolation for the edge pixel
-			alpha := 1.0 - (distance - (radius - 1.0))
-			return uint8(alpha * 255)
-		}
-		return 255
+	return ellipseAlpha(fx-centerX, fy-centerY, rx, ry)
+}
+
+// ellipseAlpha returns alpha of a pixel at distance `dx`, `dy` from center of
+// an ellipse with radii `rx`, `ry`; the edge pixel is anti-aliased.
+func ellipseAlpha(dx, dy, rx, ry float64) uint8 {
+	if rx <= 0 || ry <= 0 {
+		return 0
 	}
 
-	// Outside the radius, transparent
-	return 0
+	// normalized distance is 1 on the edge, converted to px along the shorter radius
+	distance := math.Sqrt((dx*dx)/(rx*rx) + (dy*dy)/(ry*ry))
+	outside := (distance - 1) * math.Min(rx, ry)
+	switch {
+	case outside >= 0:
+		return 0
+	case outside > -1:
+		// Linear interpolation for the edge pixel
+		return uint8(-outside * 255)
+	default:
+		return 255
+	}
 }
@@ -0,0 +1,73 @@
+package transformations
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/utils"
+)
+
+// CreateColorizeFilter creates a filter tinting the image with color of `value`
+// i.e. "<color>,<pct>", lightness of pixels is kept.
+func CreateColorizeFilter(value string) (gift.Filter, error) {
+	tint, percentage, err := utils.ParseColorizeValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	hue, saturation := hueSaturation(tint)
+	return gift.Colorize(hue, saturation, percentage), nil
+}
+
+// CreateDuotoneFilter creates a filter mapping luminance of the image to a
+// gradient between two colors of `value` i.e. "<dark>,<light>": black pixels
+// become dark color and white pixels light color.
+func CreateDuotoneFilter(value string) (gift.Filter, error) {
+	darkColor, lightColor, err := utils.ParseDuotoneValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dark := color.NRGBAModel.Convert(darkColor).(color.NRGBA)
+	light := color.NRGBAModel.Convert(lightColor).(color.NRGBA)
+	mix := func(from, to uint8, weight float32) float32 {
+		return (float32(from) + (float32(to)-float32(from))*weight) / 255
+	}
+
+	return gift.ColorFunc(func(r, g, b, a float32) (float32, float32, float32, float32) {
+		// relative luminance with Rec. 601 weights
+		luminance := 0.299*r + 0.587*g + 0.114*b
+		return mix(dark.R, light.R, luminance), mix(dark.G, light.G, luminance), mix(dark.B, light.B, luminance), a
+	}), nil
+}
+
+// hueSaturation returns hue (0 to 360) and saturation (0 to 100) of HSL
+// representation of the color.
+func hueSaturation(c color.Color) (float32, float32) {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	r, g, b := float64(nrgba.R)/255, float64(nrgba.G)/255, float64(nrgba.B)/255
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	if high == low {
+		return 0, 0 // gray
+	}
+
+	delta := high - low
+	lightness := (high + low) / 2
+	saturation := delta / (1 - math.Abs(2*lightness-1))
+
+	var hue float64
+	switch high {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+	return float32(hue), float32(saturation * 100)
+}
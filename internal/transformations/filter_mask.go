@@ -0,0 +1,98 @@
+package transformations
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/imagemeta"
+)
+
+// CreateShapeMaskFilter creates a filter keeping the image within `shape`, rest
+// is transparent. `circle` crops the image to the centered square, so that the
+// circle is perfect for non-square images e.g. avatars; `ellipse` fills the image.
+func CreateShapeMaskFilter(shape string) (gift.Filter, error) {
+	switch shape {
+	case "circle", "ellipse":
+		return &shapeMaskFilter{circle: shape == "circle"}, nil
+	default:
+		return nil, fmt.Errorf("invalid mask shape: %s. Valid shapes are: circle, ellipse", shape)
+	}
+}
+
+// CreateImageMaskFilter creates a filter using alpha of `mask` as alpha of the
+// image, e.g. a PNG with transparent areas. Luminance is used for opaque masks
+// e.g. black and white JPEG. Mask is stretched to the image size.
+func CreateImageMaskFilter(mask image.Image) (gift.Filter, error) {
+	if mask == nil {
+		return nil, fmt.Errorf("mask image cannot be empty")
+	}
+	return &imageMaskFilter{mask: mask, luminance: !imagemeta.HasAlpha(mask)}, nil
+}
+
+// shapeMaskFilter masks the image with an ellipse inscribed in its bounds
+type shapeMaskFilter struct {
+	circle bool
+}
+
+func (f *shapeMaskFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	if !f.circle {
+		return srcBounds
+	}
+	size := min(srcBounds.Dx(), srcBounds.Dy())
+	return image.Rect(0, 0, size, size)
+}
+
+func (f *shapeMaskFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	srcBounds := src.Bounds()
+	// centered crop, no-op for ellipse
+	offset := srcBounds.Min.Add(image.Pt((srcBounds.Dx()-bounds.Dx())/2, (srcBounds.Dy()-bounds.Dy())/2))
+
+	rx, ry := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	mask := image.NewAlpha(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			// distance of pixel center from center of the ellipse
+			alpha := ellipseAlpha(float64(x)+0.5-rx, float64(y)+0.5-ry, rx, ry)
+			mask.SetAlpha(bounds.Min.X+x, bounds.Min.Y+y, color.Alpha{alpha})
+		}
+	}
+
+	draw.DrawMask(dst, bounds, src, offset, mask, bounds.Min, draw.Src)
+}
+
+// imageMaskFilter masks the image with alpha or luminance of another image
+type imageMaskFilter struct {
+	mask      image.Image
+	luminance bool
+}
+
+func (f *imageMaskFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return srcBounds
+}
+
+func (f *imageMaskFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+
+	// stretch mask to the image size
+	resize := gift.New(gift.Resize(bounds.Dx(), bounds.Dy(), gift.LinearResampling))
+	resized := image.NewNRGBA(resize.Bounds(f.mask.Bounds()))
+	resize.Draw(resized, f.mask)
+
+	mask := image.NewAlpha(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := resized.NRGBAAt(x, y)
+			alpha := c.A
+			if f.luminance {
+				alpha = color.GrayModel.Convert(c).(color.Gray).Y
+			}
+			mask.SetAlpha(bounds.Min.X+x, bounds.Min.Y+y, color.Alpha{alpha})
+		}
+	}
+
+	draw.DrawMask(dst, bounds, src, src.Bounds().Min, mask, bounds.Min, draw.Src)
+}
@@ -0,0 +1,103 @@
+package transformations
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/gift"
+)
+
+// OverlayOptions controls placement of an overlay image over the base image
+type OverlayOptions struct {
+	Gravity string  // position of overlay, see utils.ParseGravityValue
+	X, Y    int     // offsets in px from the edges of gravity, towards the center
+	Scale   float64 // overlay fits in this fraction of base dimensions, 0 keeps original size
+	Opacity float64 // 0 to 1
+	Tile    bool    // repeats overlay over the whole base image starting at X, Y
+}
+
+// CreateOverlayFilter creates a filter that composites `overlay` over the image
+func CreateOverlayFilter(overlay image.Image, options OverlayOptions) (gift.Filter, error) {
+	if overlay == nil {
+		return nil, fmt.Errorf("overlay image cannot be empty")
+	}
+	if options.Opacity < 0 || options.Opacity > 1 {
+		return nil, fmt.Errorf("overlay opacity must be between 0 and 1")
+	}
+	if options.Scale < 0 || options.Scale > 1 {
+		return nil, fmt.Errorf("overlay scale must be between 0 and 1")
+	}
+
+	return &overlayFilter{overlay: overlay, options: options}, nil
+}
+
+// overlayFilter draws an image over the source image, bounds are unchanged
+type overlayFilter struct {
+	overlay image.Image
+	options OverlayOptions
+}
+
+func (f *overlayFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return srcBounds
+}
+
+func (f *overlayFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	draw.Draw(dst, bounds, src, src.Bounds().Min, draw.Src)
+
+	overlay := f.overlay
+	if f.options.Scale > 0 {
+		width := max(int(float64(bounds.Dx())*f.options.Scale), 1)
+		height := max(int(float64(bounds.Dy())*f.options.Scale), 1)
+		resize := gift.New(gift.ResizeToFit(width, height, gift.LanczosResampling))
+		scaled := image.NewNRGBA(resize.Bounds(overlay.Bounds()))
+		resize.Draw(scaled, overlay)
+		overlay = scaled
+	}
+
+	size := overlay.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(f.options.Opacity*255 + 0.5)})
+
+	if !f.options.Tile {
+		position := GravityPosition(f.options.Gravity, bounds, size, f.options.X, f.options.Y)
+		draw.DrawMask(dst, image.Rectangle{Min: position, Max: position.Add(size)}, overlay, overlay.Bounds().Min, mask, image.Point{}, draw.Over)
+		return
+	}
+
+	// offsets only shift the tiles, first tile starts at or before the edge so
+	// that large offsets do not draw tiles outside the image
+	startX := bounds.Min.X + ((f.options.X%size.X)-size.X)%size.X
+	startY := bounds.Min.Y + ((f.options.Y%size.Y)-size.Y)%size.Y
+	for y := startY; y < bounds.Max.Y; y += size.Y {
+		for x := startX; x < bounds.Max.X; x += size.X {
+			position := image.Pt(x, y)
+			draw.DrawMask(dst, image.Rectangle{Min: position, Max: position.Add(size)}, overlay, overlay.Bounds().Min, mask, image.Point{}, draw.Over)
+		}
+	}
+}
+
+// GravityPosition returns top-left position of an item of `size` placed in
+// `bounds` as per `gravity`, offsets move the item away from the edges.
+func GravityPosition(gravity string, bounds image.Rectangle, size image.Point, offsetX, offsetY int) image.Point {
+	x := bounds.Min.X + (bounds.Dx()-size.X)/2 + offsetX
+	y := bounds.Min.Y + (bounds.Dy()-size.Y)/2 + offsetY
+
+	switch gravity {
+	case "west", "northwest", "southwest":
+		x = bounds.Min.X + offsetX
+	case "east", "northeast", "southeast":
+		x = bounds.Max.X - size.X - offsetX
+	}
+	switch gravity {
+	case "north", "northwest", "northeast":
+		y = bounds.Min.Y + offsetY
+	case "south", "southwest", "southeast":
+		y = bounds.Max.Y - size.Y - offsetY
+	}
+	return image.Pt(x, y)
+}
@@ -0,0 +1,44 @@
+package transformations
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/utils"
+)
+
+// CreatePaddingFilter creates a filter adding space filled with `bgColor` around
+// the image, `value` is 1 to 4 sides in px same as CSS padding e.g. "10,20,10,20".
+func CreatePaddingFilter(value string, bgColor color.Color) (gift.Filter, error) {
+	sides, err := utils.ParseSidesValue(value, 0, 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid padding: %w", err)
+	}
+	if bgColor == nil {
+		bgColor = color.Transparent
+	}
+	return &paddingFilter{top: sides[0], right: sides[1], bottom: sides[2], left: sides[3], bgColor: bgColor}, nil
+}
+
+// paddingFilter extends bounds of the image by padding of each side
+type paddingFilter struct {
+	top, right, bottom, left int
+	bgColor                  color.Color
+}
+
+func (f *paddingFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return image.Rect(0, 0, srcBounds.Dx()+f.left+f.right, srcBounds.Dy()+f.top+f.bottom)
+}
+
+func (f *paddingFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	draw.Draw(dst, bounds, image.NewUniform(f.bgColor), image.Point{}, draw.Src)
+
+	srcBounds := src.Bounds()
+	target := image.Rectangle{Min: bounds.Min.Add(image.Pt(f.left, f.top))}
+	target.Max = target.Min.Add(srcBounds.Size())
+	draw.Draw(dst, target, src, srcBounds.Min, draw.Over)
+}
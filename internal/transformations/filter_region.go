@@ -0,0 +1,52 @@
+package transformations
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/gift"
+)
+
+// CreateRegionFilter creates a filter applying `filter` only within `regions`,
+// rest of the image is unchanged, e.g. blurring faces and license plates.
+// Regions are in px relative to the top left corner, clipped to the image.
+func CreateRegionFilter(filter gift.Filter, regions []image.Rectangle) (gift.Filter, error) {
+	if filter == nil {
+		return nil, fmt.Errorf("region filter cannot be empty")
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("at least one region is required")
+	}
+	return &regionFilter{filter: gift.New(filter), regions: regions}, nil
+}
+
+// regionFilter applies a filter to rectangles of the image
+type regionFilter struct {
+	filter  *gift.GIFT
+	regions []image.Rectangle
+}
+
+func (f *regionFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return srcBounds
+}
+
+func (f *regionFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	draw.Draw(dst, bounds, src, src.Bounds().Min, draw.Src)
+
+	for _, region := range f.regions {
+		region = region.Add(bounds.Min).Intersect(bounds)
+		if region.Empty() {
+			continue
+		}
+
+		// region is filtered on its own, e.g. blur does not pick up pixels around it;
+		// overlapping regions are filtered again
+		crop := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+		draw.Draw(crop, crop.Bounds(), dst, region.Min, draw.Src)
+		filtered := image.NewRGBA(f.filter.Bounds(crop.Bounds()))
+		f.filter.Draw(filtered, crop)
+		draw.Draw(dst, region, filtered, filtered.Bounds().Min, draw.Src)
+	}
+}
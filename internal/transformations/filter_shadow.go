@@ -0,0 +1,58 @@
+package transformations
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/utils"
+)
+
+// CreateShadowFilter creates a filter drawing a drop shadow of `value` i.e.
+// "<blur>,<offset>,<color>" behind the image. Shadow follows alpha of the image,
+// so that rounded or masked images get rounded shadows.
+func CreateShadowFilter(value string) (gift.Filter, error) {
+	shadow, err := utils.ParseShadowValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return &shadowFilter{shadow: shadow}, nil
+}
+
+// shadowFilter extends bounds of the image to fit the blurred and offset shadow
+type shadowFilter struct {
+	shadow *utils.ShadowValue
+}
+
+// margins returns space needed around the image at top, right, bottom and left
+func (f *shadowFilter) margins() (int, int, int, int) {
+	// gaussian blur is negligible beyond 3 standard deviations
+	spread := int(math.Ceil(3 * float64(f.shadow.Blur)))
+	return max(spread-f.shadow.OffsetY, 0), max(spread+f.shadow.OffsetX, 0), max(spread+f.shadow.OffsetY, 0), max(spread-f.shadow.OffsetX, 0)
+}
+
+func (f *shadowFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	top, right, bottom, left := f.margins()
+	return image.Rect(0, 0, srcBounds.Dx()+left+right, srcBounds.Dy()+top+bottom)
+}
+
+func (f *shadowFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	srcBounds := src.Bounds()
+	top, _, _, left := f.margins()
+	target := image.Rectangle{Min: bounds.Min.Add(image.Pt(left, top))}
+	target.Max = target.Min.Add(srcBounds.Size())
+
+	// alpha of the source at offset position, blurred
+	mask := image.NewAlpha(bounds)
+	draw.Draw(mask, target.Add(image.Pt(f.shadow.OffsetX, f.shadow.OffsetY)), src, srcBounds.Min, draw.Src)
+	if f.shadow.Blur > 0 {
+		blurred := image.NewAlpha(bounds)
+		gift.New(gift.GaussianBlur(f.shadow.Blur)).Draw(blurred, mask)
+		mask = blurred
+	}
+
+	draw.DrawMask(dst, bounds, image.NewUniform(f.shadow.Color), image.Point{}, mask, bounds.Min, draw.Src)
+	draw.Draw(dst, target, src, srcBounds.Min, draw.Over)
+}
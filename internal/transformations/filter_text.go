@@ -0,0 +1,135 @@
+package transformations
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"github.com/disintegration/gift"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxStrokeWidth limits stroke, it is drawn by repeating text around each position
+const maxStrokeWidth = 10
+
+// TextOptions controls appearance and placement of text drawn over the image
+type TextOptions struct {
+	Color       color.Color
+	StrokeWidth int         // in px, 0 for no stroke
+	StrokeColor color.Color // color of stroke
+	Background  color.Color // color of box behind the text, nil for no box
+	Padding     int         // space in px between text and edges of the box
+	Gravity     string      // position of the box, see utils.ParseGravityValue
+	X, Y        int         // offsets in px from the edges of gravity, towards the center
+	Width       int         // lines are wrapped to this width in px, 0 wraps to the image width
+}
+
+// CreateTextFilter creates a filter that draws `text` over the image using `face`
+func CreateTextFilter(text string, face font.Face, options TextOptions) (gift.Filter, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+	if face == nil {
+		return nil, fmt.Errorf("font face cannot be empty")
+	}
+	if options.StrokeWidth < 0 || options.StrokeWidth > maxStrokeWidth {
+		return nil, fmt.Errorf("stroke width must be between 0 and %d", maxStrokeWidth)
+	}
+	if options.Color == nil {
+		options.Color = color.White
+	}
+	if options.StrokeColor == nil {
+		options.StrokeColor = color.Black
+	}
+
+	return &textFilter{text: text, face: face, options: options}, nil
+}
+
+// textFilter draws wrapped text over the source image, bounds are unchanged
+type textFilter struct {
+	text    string
+	face    font.Face
+	options TextOptions
+}
+
+func (f *textFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return srcBounds
+}
+
+func (f *textFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := dst.Bounds()
+	draw.Draw(dst, bounds, src, src.Bounds().Min, draw.Src)
+
+	inset := 2 * (f.options.Padding + f.options.StrokeWidth)
+	width := f.options.Width
+	if width <= 0 {
+		width = bounds.Dx() - 2*abs(f.options.X) - inset
+	}
+	lines := wrapText(f.face, f.text, width)
+
+	metrics := f.face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	textWidth := 0
+	for _, line := range lines {
+		textWidth = max(textWidth, font.MeasureString(f.face, line).Ceil())
+	}
+
+	size := image.Pt(textWidth+inset, lineHeight*len(lines)+inset)
+	box := image.Rectangle{Min: GravityPosition(f.options.Gravity, bounds, size, f.options.X, f.options.Y)}
+	box.Max = box.Min.Add(size)
+	if f.options.Background != nil {
+		draw.Draw(dst, box, image.NewUniform(f.options.Background), image.Point{}, draw.Over)
+	}
+
+	origin := box.Min.Add(image.Pt(f.options.Padding+f.options.StrokeWidth, f.options.Padding+f.options.StrokeWidth+metrics.Ascent.Ceil()))
+	drawLines := func(c color.Color, dx, dy int) {
+		drawer := &font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: f.face}
+		for i, line := range lines {
+			drawer.Dot = fixed.P(origin.X+dx, origin.Y+dy+i*lineHeight)
+			drawer.DrawString(line)
+		}
+	}
+
+	// stroke is text drawn around each position within the stroke radius
+	stroke := f.options.StrokeWidth
+	for dy := -stroke; dy <= stroke && stroke > 0; dy++ {
+		for dx := -stroke; dx <= stroke; dx++ {
+			if (dx != 0 || dy != 0) && dx*dx+dy*dy <= stroke*stroke {
+				drawLines(f.options.StrokeColor, dx, dy)
+			}
+		}
+	}
+	drawLines(f.options.Color, 0, 0)
+}
+
+// wrapText splits text into lines fitting in `width` px, breaking at spaces and
+// newlines. Words wider than `width` are kept on their own line.
+func wrapText(face font.Face, text string, width int) []string {
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/disintegration/gift"
+)
+
+func TestMaskFilter(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	// left half opaque and right half transparent
+	alphaMask := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(alphaMask, image.Rect(0, 0, 2, 4), image.NewUniform(color.White), image.Point{}, draw.Src)
+	// top half white and bottom half black
+	grayMask := image.NewGray(image.Rect(0, 0, 4, 4))
+	draw.Draw(grayMask, image.Rect(0, 0, 4, 2), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name        string
+		shape       string
+		mask        image.Image
+		bounds      image.Rectangle
+		transparent []image.Point
+		opaque      []image.Point
+	}{
+		{
+			name:        "circle crops to square",
+			shape:       "circle",
+			bounds:      image.Rect(0, 0, 20, 20),
+			transparent: []image.Point{{0, 0}, {19, 19}},
+			opaque:      []image.Point{{10, 10}, {10, 1}, {1, 10}, {18, 10}},
+		},
+		{
+			name:        "ellipse fills image",
+			shape:       "ellipse",
+			bounds:      image.Rect(0, 0, 40, 20),
+			transparent: []image.Point{{0, 0}, {39, 19}, {5, 1}},
+			opaque:      []image.Point{{20, 10}, {2, 10}, {20, 2}},
+		},
+		{
+			name:        "alpha of image",
+			mask:        alphaMask,
+			bounds:      image.Rect(0, 0, 40, 20),
+			transparent: []image.Point{{39, 0}, {30, 10}},
+			opaque:      []image.Point{{0, 0}, {10, 10}},
+		},
+		{
+			name:        "luminance of opaque image",
+			mask:        grayMask,
+			bounds:      image.Rect(0, 0, 40, 20),
+			transparent: []image.Point{{0, 19}, {39, 15}},
+			opaque:      []image.Point{{0, 0}, {39, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter gift.Filter
+			var err error
+			if tt.mask != nil {
+				filter, err = CreateImageMaskFilter(tt.mask)
+			} else {
+				filter, err = CreateShapeMaskFilter(tt.shape)
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			bounds := filter.Bounds(src.Bounds())
+			if bounds != tt.bounds {
+				t.Fatalf("Expected bounds %v, got %v", tt.bounds, bounds)
+			}
+			dst := image.NewRGBA(bounds)
+			filter.Draw(dst, src, nil)
+			for _, point := range tt.transparent {
+				if a := dst.RGBAAt(point.X, point.Y).A; a != 0 {
+					t.Errorf("Expected transparent pixel at %v, got alpha %d", point, a)
+				}
+			}
+			for _, point := range tt.opaque {
+				if a := dst.RGBAAt(point.X, point.Y).A; a != 255 {
+					t.Errorf("Expected opaque pixel at %v, got alpha %d", point, a)
+				}
+			}
+		})
+	}
+
+	if _, err := CreateShapeMaskFilter("star"); err == nil {
+		t.Errorf("Expected error for unknown shape")
+	}
+}
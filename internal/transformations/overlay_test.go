@@ -0,0 +1,76 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestOverlayFilter(t *testing.T) {
+	base := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	red := color.RGBA{255, 0, 0, 255}
+	overlay := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(overlay, overlay.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	halfRed := color.RGBA{128, 0, 0, 128}
+
+	tests := []struct {
+		name    string
+		options OverlayOptions
+		pixels  map[image.Point]color.RGBA
+	}{
+		{
+			name:    "southeast with offset",
+			options: OverlayOptions{Gravity: "southeast", X: 1, Y: 1, Opacity: 1},
+			pixels:  map[image.Point]color.RGBA{{8, 8}: red, {7, 7}: red, {9, 9}: {}, {6, 6}: {}},
+		},
+		{
+			name:    "center with opacity",
+			options: OverlayOptions{Gravity: "center", Opacity: 0.5},
+			pixels:  map[image.Point]color.RGBA{{4, 4}: halfRed, {0, 0}: {}},
+		},
+		{
+			name:    "tiled",
+			options: OverlayOptions{Gravity: "center", Opacity: 1, Tile: true},
+			pixels:  map[image.Point]color.RGBA{{0, 0}: red, {9, 9}: red, {5, 0}: red},
+		},
+		{
+			name:    "tiled with offsets larger than overlay",
+			options: OverlayOptions{Opacity: 1, Tile: true, X: 3, Y: 5},
+			pixels:  map[image.Point]color.RGBA{{0, 0}: red, {9, 9}: red, {2, 4}: red},
+		},
+		{
+			name:    "tiled with large negative offsets",
+			options: OverlayOptions{Opacity: 1, Tile: true, X: -10000, Y: -9999},
+			pixels:  map[image.Point]color.RGBA{{0, 0}: red, {9, 9}: red},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := CreateOverlayFilter(overlay, tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			dst := image.NewRGBA(filter.Bounds(base.Bounds()))
+			filter.Draw(dst, base, nil)
+			for point, expected := range tt.pixels {
+				if got := dst.RGBAAt(point.X, point.Y); got != expected {
+					t.Errorf("Expected %v at %v, got %v", expected, point, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateOverlayFilter(t *testing.T) {
+	overlay := image.NewRGBA(image.Rect(0, 0, 2, 2))
+
+	if _, err := CreateOverlayFilter(nil, OverlayOptions{Opacity: 1}); err == nil {
+		t.Errorf("Expected error for missing overlay but got none")
+	}
+	if _, err := CreateOverlayFilter(overlay, OverlayOptions{Opacity: 2}); err == nil {
+		t.Errorf("Expected error for invalid opacity but got none")
+	}
+}
@@ -0,0 +1,70 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/gift"
+)
+
+func TestRegionFilter(t *testing.T) {
+	// vertical stripes, so that pixelate changes every pixel of a block
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x%2 == 0 {
+				src.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				src.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	filter, err := CreateRegionFilter(gift.Pixelate(4), []image.Rectangle{
+		image.Rect(0, 0, 8, 8),
+		image.Rect(36, 16, 60, 30), // clipped to the image
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dst := image.NewRGBA(filter.Bounds(src.Bounds()))
+	filter.Draw(dst, src, nil)
+
+	if dst.Bounds() != src.Bounds() {
+		t.Errorf("Expected bounds %v, got %v", src.Bounds(), dst.Bounds())
+	}
+
+	tests := []struct {
+		name     string
+		x, y     int
+		filtered bool
+	}{
+		{name: "inside first region", x: 2, y: 2, filtered: true},
+		{name: "inside clipped region", x: 38, y: 18, filtered: true},
+		{name: "right of first region", x: 8, y: 2, filtered: false},
+		{name: "below first region", x: 2, y: 8, filtered: false},
+		{name: "between regions", x: 20, y: 10, filtered: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, original := dst.RGBAAt(tt.x, tt.y), src.RGBAAt(tt.x, tt.y)
+			if tt.filtered && (got == original || got.R == 0 || got.R == 255) {
+				t.Errorf("Expected pixel at %d,%d to be pixelated, got %v", tt.x, tt.y, got)
+			}
+			if !tt.filtered && got != original {
+				t.Errorf("Expected pixel at %d,%d to be unchanged %v, got %v", tt.x, tt.y, original, got)
+			}
+		})
+	}
+}
+
+func TestCreateRegionFilter(t *testing.T) {
+	if _, err := CreateRegionFilter(gift.GaussianBlur(5), nil); err == nil {
+		t.Errorf("Expected error for empty regions")
+	}
+	if _, err := CreateRegionFilter(nil, []image.Rectangle{image.Rect(0, 0, 10, 10)}); err == nil {
+		t.Errorf("Expected error for empty filter")
+	}
+}
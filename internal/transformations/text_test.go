@@ -0,0 +1,113 @@
+package transformations
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestTextFilter(t *testing.T) {
+	base := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	face := basicfont.Face7x13
+	blue := color.RGBA{0, 0, 255, 255}
+
+	tests := []struct {
+		name    string
+		text    string
+		options TextOptions
+		pixels  map[image.Point]color.RGBA
+		painted image.Rectangle // some pixel of text color is expected within
+	}{
+		{
+			name:    "background box at southeast",
+			text:    "hi",
+			options: TextOptions{Background: blue, Padding: 2, Gravity: "southeast"},
+			// box is 14+4 px wide and 13+4 px high
+			pixels:  map[image.Point]color.RGBA{{99, 59}: blue, {82, 43}: blue, {81, 59}: {}, {99, 42}: {}},
+			painted: image.Rect(82, 43, 100, 60),
+		},
+		{
+			name:    "wrapped at northwest",
+			text:    "hello world",
+			options: TextOptions{Gravity: "northwest", Width: 40},
+			pixels:  map[image.Point]color.RGBA{{99, 0}: {}},
+			painted: image.Rect(0, 13, 40, 26), // second line
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := CreateTextFilter(tt.text, face, tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			dst := image.NewRGBA(filter.Bounds(base.Bounds()))
+			filter.Draw(dst, base, nil)
+			for point, expected := range tt.pixels {
+				if got := dst.RGBAAt(point.X, point.Y); got != expected {
+					t.Errorf("Expected %v at %v, got %v", expected, point, got)
+				}
+			}
+
+			painted := false
+			for y := tt.painted.Min.Y; y < tt.painted.Max.Y && !painted; y++ {
+				for x := tt.painted.Min.X; x < tt.painted.Max.X; x++ {
+					if dst.RGBAAt(x, y) == (color.RGBA{255, 255, 255, 255}) {
+						painted = true
+						break
+					}
+				}
+			}
+			if !painted {
+				t.Errorf("Expected text within %v", tt.painted)
+			}
+		})
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	face := basicfont.Face7x13 // 7 px per character
+
+	tests := []struct {
+		name     string
+		text     string
+		width    int
+		expected []string
+	}{
+		{name: "fits", text: "hello world", width: 100, expected: []string{"hello world"}},
+		{name: "wraps at spaces", text: "hello big world", width: 50, expected: []string{"hello", "big", "world"}},
+		{name: "long word", text: "a incomprehensible b", width: 30, expected: []string{"a", "incomprehensible", "b"}},
+		{name: "newlines", text: "one\ntwo", width: 100, expected: []string{"one", "two"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := wrapText(face, tt.text, tt.width)
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, lines)
+			}
+			for i := range lines {
+				if lines[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateTextFilter(t *testing.T) {
+	face := basicfont.Face7x13
+
+	if _, err := CreateTextFilter(" ", face, TextOptions{}); err == nil {
+		t.Errorf("Expected error for empty text")
+	}
+	if _, err := CreateTextFilter("hi", nil, TextOptions{}); err == nil {
+		t.Errorf("Expected error for empty font face")
+	}
+	if _, err := CreateTextFilter("hi", face, TextOptions{StrokeWidth: 11}); err == nil {
+		t.Errorf("Expected error for stroke width over limit")
+	}
+}
@@ -0,0 +1,295 @@
+// package tus implements storage of resumable uploads as per tus protocol (https://tus.io).
+// Chunks are appended to a staging file on local disk until the upload is complete.
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	ErrSizeExceeded   = errors.New("upload size exceeded")
+)
+
+// Upload is the state of a resumable upload.
+type Upload struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"` // total size in bytes
+	Offset    int64             `json:"offset"` // bytes received so far
+	Metadata  map[string]string `json:"metadata"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// IsComplete returns true when all bytes of the upload are received.
+func (u *Upload) IsComplete() bool {
+	return u.Offset == u.Length
+}
+
+// Store keeps uploads in a directory, each upload has a data file `<id>.bin`
+// and an info file `<id>.json`.
+type Store struct {
+	dir        string
+	expiration time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // per upload, chunks of an upload are written one at a time
+}
+
+// NewStore returns a Store staging uploads in `dir`, uploads not completed
+// within `expiration` are removed by RemoveExpired.
+func NewStore(dir string, expiration time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	return &Store{dir: dir, expiration: expiration, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// Create starts a new upload of `length` bytes.
+func (s *Store) Create(length int64, metadata map[string]string) (*Upload, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate upload id: %w", err)
+	}
+
+	upload := &Upload{
+		ID:        hex.EncodeToString(id),
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(s.expiration).UTC(),
+	}
+
+	file, err := os.Create(s.dataPath(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	file.Close()
+
+	if err := s.saveInfo(upload); err != nil {
+		os.Remove(s.dataPath(upload.ID))
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// Get returns the upload with `id`, ErrUploadNotFound if it is not present or expired.
+func (s *Store) Get(id string) (*Upload, error) {
+	if !isValidID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	upload := &Upload{}
+	if err := json.Unmarshal(data, upload); err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadNotFound
+	}
+
+	return upload, nil
+}
+
+// WriteChunk appends data read from `chunk` to the upload at `offset`, which
+// must be the current offset of the upload. Returns the updated upload.
+//
+// Bytes read before an error (e.g. client disconnect) are kept, so that the
+// client can resume from the new offset.
+func (s *Store) WriteChunk(id string, offset int64, chunk io.Reader) (*Upload, error) {
+	lock := s.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Offset != offset {
+		return upload, ErrOffsetMismatch
+	}
+
+	file, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	// discard bytes of previously failed writes beyond the recorded offset
+	if err := file.Truncate(upload.Offset); err != nil {
+		return nil, fmt.Errorf("failed to write upload: %w", err)
+	}
+	if _, err := file.Seek(upload.Offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to write upload: %w", err)
+	}
+
+	// read one byte more than remaining to detect chunks exceeding the upload length
+	n, copyErr := io.Copy(file, io.LimitReader(chunk, upload.Length-upload.Offset+1))
+	if n > upload.Length-upload.Offset {
+		return upload, ErrSizeExceeded
+	}
+
+	upload.Offset += n
+	if err := s.saveInfo(upload); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return upload, fmt.Errorf("failed to write upload: %w", copyErr)
+	}
+
+	return upload, nil
+}
+
+// Open returns a reader for the data of upload with `id`, caller must close it.
+func (s *Store) Open(id string) (*os.File, error) {
+	if !isValidID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	file, err := os.Open(s.dataPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrUploadNotFound
+	}
+	return file, err
+}
+
+// Remove deletes the upload with `id`, it is a no-op if the upload is not present.
+func (s *Store) Remove(id string) error {
+	if !isValidID(id) {
+		return ErrUploadNotFound
+	}
+
+	// chunks being written finish first, lock is dropped only once files are
+	// gone so that writers waiting for it find the upload removed
+	lock := s.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.Remove(s.infoPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+	if err := os.Remove(s.dataPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.locks, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// RemoveExpired deletes all uploads which expired before completion,
+// returns number of uploads removed.
+func (s *Store) RemoveExpired() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read staging directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !isValidID(id) {
+			continue
+		}
+
+		if _, err := s.Get(id); errors.Is(err, ErrUploadNotFound) {
+			if err := s.Remove(id); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *Store) saveInfo(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	// write & rename so that info is never read partially written
+	tmpPath := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.infoPath(upload.ID)); err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) lock(id string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[id] = lock
+	}
+	return lock
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// isValidID returns true if `id` is a hex string, as generated by Create;
+// guards against path traversal using ids from requests
+func isValidID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// ParseMetadata parses value of `Upload-Metadata` header i.e. comma separated
+// key value pairs where values are base64 encoded, e.g. `filename d29ybGQuanBn,is_confidential`
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("invalid metadata: empty key")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata value for key %s", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,141 @@
+package tus
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestWriteChunk(t *testing.T) {
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name           string
+		offset         int64
+		chunk          []byte
+		expectedErr    error
+		expectedOffset int64
+	}{
+		{name: "first chunk", offset: 0, chunk: data[:4], expectedOffset: 4},
+		{name: "offset mismatch", offset: 2, chunk: data[2:4], expectedErr: ErrOffsetMismatch, expectedOffset: 0},
+		{name: "chunk exceeding length", offset: 0, chunk: append(data, 'x'), expectedErr: ErrSizeExceeded, expectedOffset: 0},
+		{name: "complete upload", offset: 0, chunk: data, expectedOffset: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(t.TempDir(), time.Hour)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			upload, err := store.Create(int64(len(data)), nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			upload, err = store.WriteChunk(upload.ID, tt.offset, bytes.NewReader(tt.chunk))
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if upload.Offset != tt.expectedOffset {
+				t.Errorf("Expected offset %d, got %d", tt.expectedOffset, upload.Offset)
+			}
+
+			stored, err := store.Get(upload.ID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if stored.Offset != tt.expectedOffset {
+				t.Errorf("Expected stored offset %d, got %d", tt.expectedOffset, stored.Offset)
+			}
+		})
+	}
+}
+
+func TestWriteChunkResumesAfterPartialWrite(t *testing.T) {
+	data := []byte("0123456789")
+	store, err := NewStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	upload, err := store.Create(int64(len(data)), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// connection drops after 6 bytes
+	dropped := io.MultiReader(bytes.NewReader(data[:6]), iotest.ErrReader(errors.New("connection reset")))
+	upload, err = store.WriteChunk(upload.ID, 0, dropped)
+	if err == nil {
+		t.Errorf("Expected error for dropped connection")
+	}
+	if upload == nil || upload.Offset != 6 {
+		t.Fatalf("Expected offset 6 after partial write, got %v", upload)
+	}
+
+	upload, err = store.WriteChunk(upload.ID, 6, bytes.NewReader(data[6:]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !upload.IsComplete() {
+		t.Errorf("Expected upload to be complete, offset is %d", upload.Offset)
+	}
+
+	file, err := store.Open(upload.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	stored, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Errorf("Expected data %q, got %q", data, stored)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	dir := t.TempDir()
+	expiring, err := NewStore(dir, -time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store, err := NewStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expired, err := expiring.Create(10, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	active, err := store.Create(10, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := store.Get(expired.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected expired upload to be not found, got %v", err)
+	}
+	if _, err := store.WriteChunk(expired.ID, 0, bytes.NewReader([]byte("01"))); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected writing expired upload to fail with not found, got %v", err)
+	}
+
+	removed, err := store.RemoveExpired()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 upload removed, got %d", removed)
+	}
+	if _, err := store.Open(expired.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected data of expired upload to be removed, got %v", err)
+	}
+	if _, err := store.Get(active.ID); err != nil {
+		t.Errorf("Expected active upload to be kept, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"math"
 	"net/url"
@@ -173,6 +174,45 @@ func ParseIntValue(value string, min, max int) (int, error) {
 	return parsed, nil
 }
 
+// ParseGravityValue parses position like "northeast" or short form "ne" to
+// the long form, empty value is "center"
+func ParseGravityValue(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "c", "center":
+		return "center", nil
+	case "n", "north":
+		return "north", nil
+	case "s", "south":
+		return "south", nil
+	case "e", "east":
+		return "east", nil
+	case "w", "west":
+		return "west", nil
+	case "ne", "northeast":
+		return "northeast", nil
+	case "nw", "northwest":
+		return "northwest", nil
+	case "se", "southeast":
+		return "southeast", nil
+	case "sw", "southwest":
+		return "southwest", nil
+	default:
+		return "", fmt.Errorf("unsupported gravity: %s (supported: center, north, south, east, west, northeast, northwest, southeast, southwest or n, s, e, w, ne, nw, se, sw)", value)
+	}
+}
+
+// ParseDPRValue parses device pixel ratio like "2" or "1.5", between 1 and 4
+func ParseDPRValue(value string) (float64, error) {
+	dpr, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("value must be a valid number: %s", value)
+	}
+	if dpr < 1 || dpr > 4 {
+		return 0, fmt.Errorf("value must be between 1 and 4, got %s", value)
+	}
+	return dpr, nil
+}
+
 func ParseRotateAngle(value string) (float32, error) {
 	// Handle common rotation shortcuts
 	switch strings.ToLower(strings.TrimSpace(value)) {
@@ -225,8 +265,10 @@ func ParseFormatValue(value string) (string, error) {
 		return "png", nil
 	case "webp":
 		return "webp", nil
+	case "blurhash", "thumbhash", "datauri", "json":
+		return format, nil
 	default:
-		return "", fmt.Errorf("unsupported format: %s (supported formats: jpeg, jpg, png, webp)", value)
+		return "", fmt.Errorf("unsupported format: %s (supported formats: jpeg, jpg, png, webp, blurhash, thumbhash, datauri, json)", value)
 	}
 }
 
@@ -264,3 +306,254 @@ func ParseBorderRadiusValue(value string) (*BorderRadiusValue, error) {
 
 	return &radiusValue, nil
 }
+
+// BorderRadii holds horizontal and vertical radii of corners in order top-left,
+// top-right, bottom-right and bottom-left, same as CSS border-radius.
+type BorderRadii struct {
+	Horizontal [4]BorderRadiusValue
+	Vertical   [4]BorderRadiusValue
+}
+
+// ParseBorderRadii parses CSS border-radius like values with commas in place
+// of spaces, e.g. "10", "10,20,30,40", "50%/20%" (elliptical) or "10,20/5".
+// Vertical radii are same as horizontal when "/" is not present, it is escaped
+// as %2F in URLs. Raw "%" of percentages is kept when value is not escaped.
+func ParseBorderRadii(value string) (*BorderRadii, error) {
+	if decodedValue, err := url.PathUnescape(value); err == nil {
+		value = decodedValue
+	}
+	horizontalStr, verticalStr, isElliptical := strings.Cut(value, "/")
+
+	var radii BorderRadii
+	var err error
+	if radii.Horizontal, err = parseCornerRadii(horizontalStr); err != nil {
+		return nil, err
+	}
+	radii.Vertical = radii.Horizontal
+	if isElliptical {
+		if radii.Vertical, err = parseCornerRadii(verticalStr); err != nil {
+			return nil, err
+		}
+	}
+	return &radii, nil
+}
+
+// parseCornerRadii expands 1 to 4 comma separated values to radii of the four
+// corners, same as CSS: missing values are copied from the opposite corner.
+func parseCornerRadii(value string) ([4]BorderRadiusValue, error) {
+	var corners [4]BorderRadiusValue
+	values := strings.Split(value, ",")
+	if len(values) > 4 {
+		return corners, fmt.Errorf("border radius accepts at most 4 values, got %s", value)
+	}
+
+	parsed := make([]BorderRadiusValue, 0, len(values))
+	for _, v := range values {
+		radius, err := ParseBorderRadiusValue(strings.TrimSpace(v))
+		if err != nil {
+			return corners, err
+		}
+		parsed = append(parsed, *radius)
+	}
+
+	switch len(parsed) {
+	case 1:
+		corners = [4]BorderRadiusValue{parsed[0], parsed[0], parsed[0], parsed[0]}
+	case 2:
+		corners = [4]BorderRadiusValue{parsed[0], parsed[1], parsed[0], parsed[1]}
+	case 3:
+		corners = [4]BorderRadiusValue{parsed[0], parsed[1], parsed[2], parsed[1]}
+	case 4:
+		corners = [4]BorderRadiusValue{parsed[0], parsed[1], parsed[2], parsed[3]}
+	}
+	return corners, nil
+}
+
+// ParseSidesValue parses 1 to 4 comma separated ints between `min` and `max`
+// for top, right, bottom and left sides, expanded same as CSS e.g. "10,20" is
+// 10 for top and bottom, 20 for right and left.
+func ParseSidesValue(value string, min, max int) ([4]int, error) {
+	var sides [4]int
+	values := strings.Split(value, ",")
+	if len(values) > 4 {
+		return sides, fmt.Errorf("at most 4 values are accepted, got %s", value)
+	}
+
+	parsed := make([]int, 0, len(values))
+	for _, v := range values {
+		side, err := ParseIntValue(strings.TrimSpace(strings.TrimSuffix(v, "px")), min, max)
+		if err != nil {
+			return sides, err
+		}
+		parsed = append(parsed, side)
+	}
+
+	switch len(parsed) {
+	case 1:
+		sides = [4]int{parsed[0], parsed[0], parsed[0], parsed[0]}
+	case 2:
+		sides = [4]int{parsed[0], parsed[1], parsed[0], parsed[1]}
+	case 3:
+		sides = [4]int{parsed[0], parsed[1], parsed[2], parsed[1]}
+	case 4:
+		sides = [4]int{parsed[0], parsed[1], parsed[2], parsed[3]}
+	}
+	return sides, nil
+}
+
+// ParseBorderValue parses "<width>,<color>" e.g. "4,%23ffffff", color is black
+// when not present.
+func ParseBorderValue(value string) (int, color.Color, error) {
+	widthStr, colorStr, hasColor := strings.Cut(value, ",")
+	width, err := ParseIntValue(strings.TrimSpace(strings.TrimSuffix(widthStr, "px")), 1, 100)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid border width: %w", err)
+	}
+
+	borderColor := color.Color(color.Black)
+	if hasColor {
+		if borderColor, err = ParseBackgroundColor(strings.TrimSpace(colorStr)); err != nil {
+			return 0, nil, fmt.Errorf("invalid border color: %w", err)
+		}
+	}
+	return width, borderColor, nil
+}
+
+// ShadowValue represents a drop shadow
+type ShadowValue struct {
+	Blur             float32 // standard deviation of gaussian blur in px
+	OffsetX, OffsetY int
+	Color            color.Color
+}
+
+// ParseShadowValue parses "<blur>,<offset>,<color>" e.g. "8,4,%2300000080".
+// Offset is vertical, or "<x>:<y>" for both; defaults are offset 0 and 50%
+// transparent black.
+func ParseShadowValue(value string) (*ShadowValue, error) {
+	parts := strings.SplitN(value, ",", 3)
+	shadow := &ShadowValue{Color: color.RGBA{0, 0, 0, 128}}
+
+	blur, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 32)
+	if err != nil || blur < 0 || blur > 100 {
+		return nil, fmt.Errorf("shadow blur must be between 0 and 100, got %s", parts[0])
+	}
+	shadow.Blur = float32(blur)
+
+	if len(parts) > 1 {
+		xStr, yStr, hasX := strings.Cut(strings.TrimSpace(parts[1]), ":")
+		if !hasX {
+			xStr, yStr = "0", xStr
+		}
+		if shadow.OffsetX, err = ParseIntValue(xStr, -100, 100); err != nil {
+			return nil, fmt.Errorf("invalid shadow offset: %w", err)
+		}
+		if shadow.OffsetY, err = ParseIntValue(yStr, -100, 100); err != nil {
+			return nil, fmt.Errorf("invalid shadow offset: %w", err)
+		}
+	}
+
+	if len(parts) > 2 {
+		if shadow.Color, err = ParseBackgroundColor(strings.TrimSpace(parts[2])); err != nil {
+			return nil, fmt.Errorf("invalid shadow color: %w", err)
+		}
+	}
+	return shadow, nil
+}
+
+// ParseColorizeValue parses "<color>,<pct>" e.g. "%230055ff,60", strength is
+// 100% when not present. Color may be rgb() e.g. "rgb(0,85,255),60".
+func ParseColorizeValue(value string) (color.Color, float32, error) {
+	parts := splitColors(value)
+	if len(parts) > 2 {
+		return nil, 0, fmt.Errorf("colorize requires a color and optional percentage, got %s", value)
+	}
+	tint, err := ParseBackgroundColor(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid colorize color: %w", err)
+	}
+
+	percentage := float32(100)
+	if len(parts) == 2 {
+		pctStr := parts[1]
+		parsed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(pctStr), "%"), 32)
+		if err != nil || parsed < 0 || parsed > 100 {
+			return nil, 0, fmt.Errorf("colorize percentage must be between 0 and 100, got %s", pctStr)
+		}
+		percentage = float32(parsed)
+	}
+	return tint, percentage, nil
+}
+
+// ParseDuotoneValue parses "<dark>,<light>" colors e.g. "%231e1b4b,%23f472b6"
+// or "rgb(30,27,75),rgb(244,114,182)".
+func ParseDuotoneValue(value string) (color.Color, color.Color, error) {
+	parts := splitColors(value)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("duotone requires dark and light colors, got %s", value)
+	}
+
+	dark, err := ParseBackgroundColor(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid duotone dark color: %w", err)
+	}
+	light, err := ParseBackgroundColor(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid duotone light color: %w", err)
+	}
+	return dark, light, nil
+}
+
+// splitColors splits `value` on commas which are not within parentheses, so
+// that rgb() colors stay whole. Parentheses may be URL encoded i.e. %28 and %29.
+func splitColors(value string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '(' || strings.HasPrefix(value[i:], "%28"):
+			depth++
+		case value[i] == ')' || strings.HasPrefix(value[i:], "%29"):
+			depth = max(depth-1, 0)
+		case value[i] == ',' && depth == 0:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// maxRegions limits regions of a single transformation e.g. faces to redact
+const maxRegions = 50
+
+// ParseRegionsValue parses value of a transformation scoped to regions e.g.
+// "20@10,10,50,50@100,40,30,30", returns the value before the first `@` and
+// regions as `x,y,width,height` in px. Regions are empty when there is no `@`.
+func ParseRegionsValue(value string) (string, []image.Rectangle, error) {
+	parts := strings.Split(value, "@")
+	if len(parts)-1 > maxRegions {
+		return "", nil, fmt.Errorf("too many regions: at most %d are allowed, got %d", maxRegions, len(parts)-1)
+	}
+
+	regions := make([]image.Rectangle, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		coords := strings.Split(part, ",")
+		if len(coords) != 4 {
+			return "", nil, fmt.Errorf("region must be x,y,width,height, got %s", part)
+		}
+
+		var values [4]int
+		for i, coord := range coords {
+			lowest := 0
+			if i >= 2 { // width and height
+				lowest = 1
+			}
+			parsed, err := ParseIntValue(strings.TrimSpace(coord), lowest, 10000)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid region %s: %w", part, err)
+			}
+			values[i] = parsed
+		}
+		regions = append(regions, image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]))
+	}
+	return parts[0], regions, nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColorizeValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		tint       color.Color
+		percentage float32
+		wantErr    bool
+	}{
+		{name: "hex", value: "%230055ff", tint: color.RGBA{0, 85, 255, 255}, percentage: 100},
+		{name: "hex with percentage", value: "%230055ff,60", tint: color.RGBA{0, 85, 255, 255}, percentage: 60},
+		{name: "rgb", value: "rgb(0,85,255)", tint: color.RGBA{0, 85, 255, 255}, percentage: 100},
+		{name: "rgb with percentage", value: "rgb(0,85,255),60%", tint: color.RGBA{0, 85, 255, 255}, percentage: 60},
+		{name: "encoded rgb with percentage", value: "rgb%280,85,255%29,60", tint: color.RGBA{0, 85, 255, 255}, percentage: 60},
+		{name: "percentage out of range", value: "rgb(0,85,255),120", wantErr: true},
+		{name: "too many values", value: "red,60,20", wantErr: true},
+		{name: "invalid color", value: "nope,60", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tint, percentage, err := ParseColorizeValue(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !sameColor(tint, tt.tint) || percentage != tt.percentage {
+				t.Errorf("Expected %v at %v%%, got %v at %v%%", tt.tint, tt.percentage, tint, percentage)
+			}
+		})
+	}
+}
+
+func TestParseDuotoneValue(t *testing.T) {
+	dark, light := color.RGBA{30, 27, 75, 255}, color.RGBA{244, 114, 182, 255}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "hex", value: "%231e1b4b,%23f472b6"},
+		{name: "rgb", value: "rgb(30,27,75),rgb(244,114,182)"},
+		{name: "mixed", value: "rgb(30, 27, 75), %23f472b6"},
+		{name: "encoded rgb", value: "rgb%2830,27,75%29,rgb%28244,114,182%29"},
+		{name: "single color", value: "rgb(30,27,75)", wantErr: true},
+		{name: "three colors", value: "%231e1b4b,%23f472b6,%23ffffff", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDark, gotLight, err := ParseDuotoneValue(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !sameColor(gotDark, dark) || !sameColor(gotLight, light) {
+				t.Errorf("Expected %v and %v, got %v and %v", dark, light, gotDark, gotLight)
+			}
+		})
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
@@ -3,33 +3,52 @@ package kritiimages
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"math"
+	"path"
 	"strings"
 
 	"github.com/chai2010/webp"
 	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/imagemeta"
+	"github.com/kritihq/kriti-images/internal/imagesources"
+	"github.com/kritihq/kriti-images/internal/placeholder"
 )
 
 var (
-	ErrSourceImageNotFound     = errors.New("source image not found")
-	ErrTransformationsNotFound = errors.New("failed to get transformations")
-	ErrInvalidImageFormat      = errors.New("unsupported image format")
-	ErrFailedToEncodeImage     = errors.New("failed to encode image to provided format")
+	ErrSourceImageNotFound           = imagesources.ErrImageNotFound
+	ErrSourceImageTooLarge           = imagesources.ErrImageTooLarge
+	ErrSourceImageDimensionsTooLarge = imagesources.ErrImageDimensionsTooLarge
+	ErrInvalidSourceImage            = imagesources.ErrInvalidImage
+	ErrTransformationsNotFound       = errors.New("failed to get transformations")
+	ErrInvalidImageFormat            = errors.New("unsupported image format")
+	ErrFailedToEncodeImage           = errors.New("failed to encode image to provided format")
 
 	ErInvalidImageSources = errors.New("invalid imagesource instance provided")
 )
 
+// swatchSize is width and height of each color of palette swatch images
+const swatchSize = 50
+
 // DestinationImage represents the desired output image properties.
 type DestinationImage struct {
-	BgColor color.Color
-	Width   int
-	Height  int
-	Format  string
-	Quality int // lossy quality for JPEG & WEBP, 1 to 100, higher is better
+	BgColor    color.Color
+	BgDominant bool // BgColor is replaced by dominant color of the source image
+	Width      int
+	Height     int
+	Format     string
+	Quality    int    // lossy quality for JPEG & WEBP, 1 to 100, higher is better
+	Palette    int    // when > 0, palette of this many colors is returned instead of the image, see formatPalette
+	Mask       string // circle, ellipse or path of an alpha mask image, applied after other transformations
+	Overlays   []Overlay
+	Text       *Text // caption drawn before overlays
 }
 
 // New creates a new instance of KritiImages.
@@ -51,6 +70,17 @@ func New(sources map[string]ImageSource, defaultSrc ImageSource) *KritiImages {
 type KritiImages struct {
 	DefaultSource ImageSource
 	Sources       map[string]ImageSource
+
+	// Watermarks are overlays drawn over every transformed image of the source,
+	// keyed by source name as in Sources.
+	Watermarks map[string]Overlay
+
+	// Fonts loads fonts for text transformation, only bundled fonts are
+	// available when nil.
+	Fonts FontLoader
+
+	// Templates are rendered by name with Render, e.g. social cards.
+	Templates map[string]*Template
 }
 
 // Transform transforms an image from a given source into a desired output format.
@@ -76,34 +106,86 @@ func (k *KritiImages) Transform(ctx context.Context, path string, dest *Destinat
 	if dest.Quality <= 0 {
 		dest.Quality = 100
 	}
+	if dest.BgDominant {
+		if colors := imagemeta.DominantColors(img, 1); len(colors) > 0 {
+			dest.BgColor = colors[0]
+		}
+	}
 
 	filters, err := getFilters(options, dest)
 	if err != nil {
 		return nil, errors.Join(ErrTransformationsNotFound, err)
 	}
+	if dest.Mask != "" {
+		mask, err := k.maskFilter(ctx, dest.Mask)
+		if err != nil {
+			return nil, errors.Join(ErrTransformationsNotFound, err)
+		}
+		filters = append(filters, mask)
+	}
+	decorations, err := getDecorationFilters(options)
+	if err != nil {
+		return nil, errors.Join(ErrTransformationsNotFound, err)
+	}
+	filters = append(filters, decorations...)
+	if dest.Text != nil {
+		text, err := k.textFilter(dest.Text)
+		if err != nil {
+			return nil, errors.Join(ErrTransformationsNotFound, err)
+		}
+		filters = append(filters, text)
+	}
+	overlays, err := k.overlayFilters(ctx, path, dest.Overlays)
+	if err != nil {
+		return nil, errors.Join(ErrTransformationsNotFound, err)
+	}
+	filters = append(filters, overlays...)
 	g := gift.New(filters...)
 
 	// create destination image
 	dstBounds := g.Bounds(img.Bounds())
 	dst := image.NewRGBA(dstBounds)
 
-	// apply background color if needed
-	if dest.BgColor != color.Transparent {
-		bounds := dst.Bounds()
-		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-			for x := bounds.Min.X; x < bounds.Max.X; x++ {
-				dst.Set(x, y, dest.BgColor)
-			}
-		}
-	}
-
 	// apply transformations
 	g.Draw(dst, img)
 
+	// apply background color if needed, it shows through transparent areas e.g.
+	// rotated or rounded corners and shadows
+	if dest.BgColor != nil && dest.BgColor != color.Transparent {
+		background := image.NewRGBA(dstBounds)
+		draw.Draw(background, dstBounds, image.NewUniform(dest.BgColor), image.Point{}, draw.Src)
+		draw.Draw(background, dstBounds, dst, dstBounds.Min, draw.Over)
+		dst = background
+	}
+
+	if dest.Palette > 0 {
+		return k.formatPalette(dst, dest.Palette, dest.Format, dest.Quality)
+	}
+
 	// encode output using format from transformation context
 	return k.formatTo(dst, dest.Format, dest.Quality)
 }
 
+// Version returns the version of the source image at `path` without fetching it.
+// Returns nil version if the ImageSource does not implement ImageVersioner.
+func (k *KritiImages) Version(ctx context.Context, path string) (*ImageVersion, error) {
+	versioner, ok := k.getImageSource(path).(ImageVersioner)
+	if !ok {
+		return nil, nil
+	}
+	return versioner.VersionImage(ctx, path)
+}
+
+// CleanPath returns the canonical path of a source image, so that spellings like
+// `./a.jpg` and `x/../a.jpg` of `a.jpg` refer to same image in caches and CDNs.
+// URLs of http source are returned as is.
+func CleanPath(imagePath string) string {
+	if imagePath == "" || strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://") {
+		return imagePath
+	}
+	return path.Clean(imagePath)
+}
+
 func (k *KritiImages) getImageSource(path string) ImageSource {
 	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
 		return k.Sources["http"]
@@ -128,9 +210,77 @@ func (k *KritiImages) formatTo(image image.Image, format string, quality int) (*
 		if err := webp.Encode(out, image, &webp.Options{Quality: float32(quality)}); err != nil {
 			return nil, errors.Join(ErrFailedToEncodeImage, err)
 		}
+	case "blurhash":
+		out.WriteString(placeholder.BlurHash(placeholderImage(image), 4, 3))
+	case "thumbhash":
+		out.WriteString(base64.StdEncoding.EncodeToString(placeholder.ThumbHash(placeholderImage(image))))
+	case "datauri":
+		encoded := new(bytes.Buffer)
+		if err := webp.Encode(encoded, image, &webp.Options{Quality: float32(quality)}); err != nil {
+			return nil, errors.Join(ErrFailedToEncodeImage, err)
+		}
+		out.WriteString("data:image/webp;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()))
 	default:
 		return nil, ErrInvalidImageFormat
 	}
 
 	return out, nil
 }
+
+// formatPalette encodes palette of `count` colors of the image, as JSON when
+// `format` is json, else as swatch image of the format with a stripe per color.
+func (k *KritiImages) formatPalette(img image.Image, count int, format string, quality int) (*bytes.Buffer, error) {
+	palette := imagemeta.Palette(img, count)
+
+	if format == "json" {
+		type swatch struct {
+			Color      string  `json:"color"`
+			Population float64 `json:"population"`
+		}
+		colors := make([]swatch, 0, len(palette))
+		for _, s := range palette {
+			colors = append(colors, swatch{Color: imagemeta.Hex(s.Color), Population: math.Round(s.Population*1000) / 1000})
+		}
+
+		out := new(bytes.Buffer)
+		if err := json.NewEncoder(out).Encode(map[string]any{"colors": colors}); err != nil {
+			return nil, errors.Join(ErrFailedToEncodeImage, err)
+		}
+		return out, nil
+	}
+
+	swatches := image.NewRGBA(image.Rect(0, 0, max(len(palette), 1)*swatchSize, swatchSize))
+	for i, s := range palette {
+		draw.Draw(swatches, image.Rect(i*swatchSize, 0, (i+1)*swatchSize, swatchSize), image.NewUniform(s.Color), image.Point{}, draw.Src)
+	}
+	return k.formatTo(swatches, format, quality)
+}
+
+// IsPlaceholderFormat returns true for formats which are encoded as text
+// instead of an image, e.g. blurhash.
+func IsPlaceholderFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "blurhash", "thumbhash", "datauri":
+		return true
+	}
+	return false
+}
+
+// IsTextFormat returns true for formats which are not images, i.e. placeholders
+// and JSON palettes.
+func IsTextFormat(format string) bool {
+	return IsPlaceholderFormat(format) || strings.ToLower(format) == "json"
+}
+
+// placeholderImage downscales the image to fit in 100x100, placeholders are
+// blurry anyway and encoding cost grows with pixels.
+func placeholderImage(img image.Image) image.Image {
+	if img.Bounds().Dx() <= 100 && img.Bounds().Dy() <= 100 {
+		return img
+	}
+
+	g := gift.New(gift.ResizeToFit(100, 100, gift.LinearResampling))
+	dst := image.NewNRGBA(g.Bounds(img.Bounds()))
+	g.Draw(dst, img)
+	return dst
+}
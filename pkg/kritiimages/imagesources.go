@@ -2,8 +2,14 @@ package kritiimages
 
 import (
 	"context"
+	"fmt"
 	"image"
+	"io"
+	"net/url"
+	"time"
 
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/kritihq/kriti-images/internal/imagesources"
 )
@@ -29,6 +35,76 @@ type ImageSource interface {
 	//
 	// NOTE: method is experimental and may be removed in future.
 	UploadImage(ctx context.Context, fileName string, file image.Image) error
+
+	// PutImage stores `size` bytes read from `data` as the image with name `fileName`
+	// without re-encoding, i.e. the original file including its metadata is preserved.
+	//
+	// Content is validated before storing, magic bytes must match the extension
+	// of `fileName` (JPEG, PNG or WEBP) and dimensions are checked using image header.
+	//
+	// Not all ImageSources support upload, e.g. URL based ImageSource which pulls images from any HTTP(s) URL.
+	//
+	// NOTE: method is experimental and may be removed in future.
+	PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error
+}
+
+// ImageStat holds metadata of an image stored in a source.
+type ImageStat = imagesources.ImageStat
+
+// ImageList is a page of images returned by ImageLister.
+type ImageList = imagesources.ImageList
+
+// ImageDeleter is implemented by ImageSources which support deleting images.
+type ImageDeleter interface {
+	// DeleteImage deletes the image with name `fileName` from the source.
+	// ErrSourceImageNotFound is returned if the image is not present.
+	DeleteImage(ctx context.Context, fileName string) error
+}
+
+// ImageStater is implemented by ImageSources which support reading image metadata
+// i.e. size, dimensions, format, modified time and content hash.
+type ImageStater interface {
+	// StatImage returns metadata of the image with name `fileName` without decoding it.
+	// ErrSourceImageNotFound is returned if the image is not present.
+	StatImage(ctx context.Context, fileName string) (*ImageStat, error)
+}
+
+// ImageVersion identifies the content of a stored image, it changes whenever the image is modified.
+type ImageVersion = imagesources.ImageVersion
+
+// ImageVersioner is implemented by ImageSources which can read version of an
+// image without fetching it, e.g. to answer conditional requests.
+type ImageVersioner interface {
+	// VersionImage returns the current version of the image with name `fileName`.
+	// ErrSourceImageNotFound is returned if the image is not present.
+	VersionImage(ctx context.Context, fileName string) (*ImageVersion, error)
+}
+
+// ImageReader is implemented by ImageSources which can return the original
+// encoded bytes of an image, e.g. to read metadata lost while decoding.
+type ImageReader interface {
+	// ReadImage returns content of the image with name `fileName` as stored,
+	// validated same as GetImage using the image header.
+	// ErrSourceImageNotFound is returned if the image is not present.
+	ReadImage(ctx context.Context, fileName string) ([]byte, error)
+}
+
+// ImageLister is implemented by ImageSources which support listing images.
+type ImageLister interface {
+	// ListImages returns at most `limit` images with names starting with `prefix`.
+	// `cursor` is NextCursor of the previous page, empty for the first page.
+	ListImages(ctx context.Context, prefix, cursor string, limit int) (*ImageList, error)
+}
+
+// PresignedUpload is a request which uploads an image directly to the source storage.
+type PresignedUpload = imagesources.PresignedUpload
+
+// ImagePresigner is implemented by ImageSources which support direct uploads to
+// their storage using presigned requests, e.g. AWS S3.
+type ImagePresigner interface {
+	// PresignUpload returns a request to upload an image of exactly `size` bytes
+	// with given `contentType` as `fileName`, valid for `expires` duration.
+	PresignUpload(ctx context.Context, fileName, contentType string, size int64, expires time.Duration) (*PresignedUpload, error)
 }
 
 func NewImageSourceLocal(basePath string, validations *imagesources.SourceImageValidations) *imagesources.ImageSourceLocal {
@@ -44,6 +120,24 @@ func NewImageSourceURL(validations *imagesources.SourceImageValidations) *images
 	}
 }
 
+// NewImageSourceWebFolder returns an ImageSource which resolves image paths relative to `baseURL`.
+// Provided headers are sent with every request to the origin, e.g. for authentication.
+func NewImageSourceWebFolder(baseURL string, headers map[string]string, validations *imagesources.SourceImageValidations) (*imagesources.ImageSourceWebFolder, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL: scheme must be http or https")
+	}
+
+	return &imagesources.ImageSourceWebFolder{
+		SourceImageValidations: *validations,
+		BaseURL:                parsed,
+		Headers:                headers,
+	}, nil
+}
+
 func NewImageSourceS3(ctx context.Context, bucket string, client *s3.Client, validations *imagesources.SourceImageValidations) *imagesources.ImageSourceS3 {
 	return &imagesources.ImageSourceS3{
 		SourceImageValidations: *validations,
@@ -51,3 +145,19 @@ func NewImageSourceS3(ctx context.Context, bucket string, client *s3.Client, val
 		Client:                 client,
 	}
 }
+
+func NewImageSourceGCS(ctx context.Context, bucket string, client *storage.Client, validations *imagesources.SourceImageValidations) *imagesources.ImageSourceGCS {
+	return &imagesources.ImageSourceGCS{
+		SourceImageValidations: *validations,
+		Bucket:                 bucket,
+		Client:                 client,
+	}
+}
+
+func NewImageSourceAzureBlob(ctx context.Context, container string, client *azblob.Client, validations *imagesources.SourceImageValidations) *imagesources.ImageSourceAzureBlob {
+	return &imagesources.ImageSourceAzureBlob{
+		SourceImageValidations: *validations,
+		Container:              container,
+		Client:                 client,
+	}
+}
@@ -0,0 +1,72 @@
+package kritiimages
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/kritihq/kriti-images/internal/imagemeta"
+)
+
+// dominantColorsCount is number of dominant colors in ImageInfo
+const dominantColorsCount = 5
+
+// ImageInfo describes a source image, see Info.
+type ImageInfo struct {
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	Format         string   `json:"format"`
+	Size           int64    `json:"size"` // in bytes, 0 when the source can not read original bytes
+	HasAlpha       bool     `json:"has_alpha"`
+	Orientation    int      `json:"orientation"`     // EXIF orientation, 1 to 8
+	ColorProfile   string   `json:"color_profile"`   // description of embedded ICC profile
+	DominantColors []string `json:"dominant_colors"` // hex colors, most frequent first
+}
+
+// Info returns intrinsic properties of the image at `path` without transforming it.
+// Size, orientation and color profile are read from original bytes when the
+// source implements ImageReader, else they are left as defaults.
+func (k *KritiImages) Info(ctx context.Context, path string) (*ImageInfo, error) {
+	source := k.getImageSource(path)
+
+	reader, ok := source.(ImageReader)
+	if !ok {
+		img, format, err := source.GetImage(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return imageInfo(img, format), nil
+	}
+
+	data, err := reader.ReadImage(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	info := imageInfo(img, format)
+	info.Size = int64(len(data))
+	info.Orientation = imagemeta.Orientation(data)
+	info.ColorProfile = imagemeta.ColorProfile(data)
+	return info, nil
+}
+
+func imageInfo(img image.Image, format string) *ImageInfo {
+	colors := make([]string, 0, dominantColorsCount)
+	for _, c := range imagemeta.DominantColors(img, dominantColorsCount) {
+		colors = append(colors, imagemeta.Hex(c))
+	}
+
+	return &ImageInfo{
+		Width:          img.Bounds().Dx(),
+		Height:         img.Bounds().Dy(),
+		Format:         format,
+		HasAlpha:       imagemeta.HasAlpha(img),
+		Orientation:    1,
+		DominantColors: colors,
+	}
+}
@@ -0,0 +1,26 @@
+package kritiimages
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/transformations"
+)
+
+// maskFilter returns filter masking the image with shape `mask` i.e. circle or
+// ellipse, else with the image at path `mask` fetched from its source. Watermark
+// of the source is applied to the mask image, else luminance masks would reveal
+// images of watermarked sources without it.
+func (k *KritiImages) maskFilter(ctx context.Context, mask string) (gift.Filter, error) {
+	if mask == "circle" || mask == "ellipse" {
+		return transformations.CreateShapeMaskFilter(mask)
+	}
+
+	img, err := k.watermarkedImage(ctx, mask)
+	if err != nil {
+		// not wrapped, missing mask must not be reported as missing source image
+		return nil, fmt.Errorf("failed to get mask image %s: %s", mask, err.Error())
+	}
+	return transformations.CreateImageMaskFilter(img)
+}
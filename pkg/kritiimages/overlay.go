@@ -0,0 +1,128 @@
+package kritiimages
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/url"
+	"strconv"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/transformations"
+)
+
+// Overlay is an image composited over the transformed image, e.g. a logo or watermark.
+type Overlay struct {
+	Path    string  // image path in any configured source, URL for http source
+	Gravity string  // e.g. center, northeast, see utils.ParseGravityValue
+	X, Y    int     // offsets in px from the edges of gravity
+	Scale   float64 // overlay fits in this fraction of the image dimensions, 0 keeps original size
+	Opacity float64 // 0 to 1
+	Tile    bool    // repeats overlay over the whole image
+}
+
+// String returns the overlay as transformation options, e.g. to use as cache key.
+func (o *Overlay) String() string {
+	return fmt.Sprintf("overlay=%s,overlay_gravity=%s,overlay_opacity=%s,overlay_scale=%s,overlay_tile=%t,overlay_x=%d,overlay_y=%d",
+		url.PathEscape(o.Path), o.Gravity, strconv.FormatFloat(o.Opacity*100, 'f', -1, 64), strconv.FormatFloat(o.Scale, 'f', -1, 64), o.Tile, o.X, o.Y)
+}
+
+// Watermark returns the mandatory overlay of the source of `path`, nil if the
+// source has none. Watermarks are set in KritiImages.Watermarks by source name.
+func (k *KritiImages) Watermark(path string) *Overlay {
+	watermark, ok := k.Watermarks[k.SourceName(path)]
+	if !ok {
+		return nil
+	}
+	return &watermark
+}
+
+// SourceName returns name of the ImageSource serving `path`, as in KritiImages.Sources.
+func (k *KritiImages) SourceName(path string) string {
+	source := k.getImageSource(path)
+	for name, s := range k.Sources {
+		if s == source {
+			return name
+		}
+	}
+	return ""
+}
+
+// overlayFilters fetches overlay images and returns filters compositing them,
+// watermark of the source of `path` is drawn last i.e. over everything.
+func (k *KritiImages) overlayFilters(ctx context.Context, path string, overlays []Overlay) ([]gift.Filter, error) {
+	filters := make([]gift.Filter, 0, len(overlays)+1)
+	for _, overlay := range overlays {
+		// overlays may be images of any source, so that their watermark applies too
+		img, err := k.watermarkedImage(ctx, overlay.Path)
+		if err != nil {
+			// not wrapped, missing overlay must not be reported as missing source image
+			return nil, fmt.Errorf("failed to get overlay image %s: %s", overlay.Path, err.Error())
+		}
+
+		filter, err := overlay.filter(img)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	if watermark := k.Watermark(path); watermark != nil {
+		filter, err := k.watermarkFilter(ctx, watermark)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// watermarkFilter returns filter compositing `watermark`. Watermark image is
+// used as is, even if its source has a watermark, it is set by the operator.
+func (k *KritiImages) watermarkFilter(ctx context.Context, watermark *Overlay) (gift.Filter, error) {
+	img, _, err := k.getImageSource(watermark.Path).GetImage(ctx, watermark.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watermark image %s: %s", watermark.Path, err.Error())
+	}
+	return watermark.filter(img)
+}
+
+// watermarkedImage fetches the image at `path` with watermark of its source
+// applied, images of sources with a watermark must never be used without it
+// e.g. as overlay or mask of another image.
+func (k *KritiImages) watermarkedImage(ctx context.Context, path string) (image.Image, error) {
+	img, _, err := k.getImageSource(path).GetImage(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	watermark := k.Watermark(path)
+	if watermark == nil {
+		return img, nil
+	}
+	filter, err := k.watermarkFilter(ctx, watermark)
+	if err != nil {
+		return nil, err
+	}
+
+	g := gift.New(filter)
+	dst := image.NewRGBA(g.Bounds(img.Bounds()))
+	g.Draw(dst, img)
+	return dst, nil
+}
+
+// filter returns filter compositing `img` with options of the overlay.
+func (o *Overlay) filter(img image.Image) (gift.Filter, error) {
+	filter, err := transformations.CreateOverlayFilter(img, transformations.OverlayOptions{
+		Gravity: o.Gravity,
+		X:       o.X,
+		Y:       o.Y,
+		Scale:   o.Scale,
+		Opacity: o.Opacity,
+		Tile:    o.Tile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create overlay filter: %w", err)
+	}
+	return filter, nil
+}
@@ -0,0 +1,118 @@
+package kritiimages
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// memorySource serves images from memory, upload is not supported.
+type memorySource struct {
+	images map[string]image.Image
+}
+
+func (m *memorySource) GetImage(ctx context.Context, fileName string) (image.Image, string, error) {
+	img, ok := m.images[fileName]
+	if !ok {
+		return nil, "", ErrSourceImageNotFound
+	}
+	return img, "png", nil
+}
+
+func (m *memorySource) UploadImage(ctx context.Context, fileName string, file image.Image) error {
+	return errors.New("upload not supported")
+}
+
+func (m *memorySource) PutImage(ctx context.Context, fileName string, data io.Reader, size int64) error {
+	return errors.New("upload not supported")
+}
+
+func uniformImage(width, height int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+var (
+	red  = color.RGBA{255, 0, 0, 255}
+	blue = color.RGBA{0, 0, 255, 255}
+)
+
+// newWatermarkedKriti returns KritiImages with watermarked local source holding
+// protected.png, and http source without watermark holding a white base image.
+func newWatermarkedKriti() *KritiImages {
+	local := &memorySource{images: map[string]image.Image{
+		"protected.png": uniformImage(20, 20, red),
+		"wm.png":        uniformImage(4, 4, blue),
+	}}
+	http := &memorySource{images: map[string]image.Image{
+		"https://example.com/base.png": uniformImage(20, 20, color.White),
+	}}
+
+	k := New(map[string]ImageSource{"local": local, "http": http}, local)
+	k.Watermarks = map[string]Overlay{
+		"local": {Path: "wm.png", Gravity: "northwest", Opacity: 1},
+	}
+	return k
+}
+
+func TestTransformWatermarksSourcesOfOverlaysAndMasks(t *testing.T) {
+	tests := []struct {
+		name string
+		dest DestinationImage
+	}{
+		{
+			name: "overlay of watermarked source",
+			dest: DestinationImage{Overlays: []Overlay{{Path: "protected.png", Gravity: "center", Opacity: 1}}},
+		},
+		{
+			name: "mask of watermarked source",
+			dest: DestinationImage{Mask: "protected.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newWatermarkedKriti()
+			dest := tt.dest
+			dest.Format = "png"
+
+			out, err := k.Transform(context.Background(), "https://example.com/base.png", &dest, map[TransformationOption]string{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("Unexpected error decoding result: %v", err)
+			}
+
+			// watermark covers top left corner of protected.png, rest is red
+			watermarked, plain := img.At(1, 1), img.At(10, 10)
+			if watermarked == plain {
+				t.Errorf("Expected watermark at top left corner, got %v same as center", watermarked)
+			}
+		})
+	}
+}
+
+func TestWatermarkedImageUsesWatermarkImageAsIs(t *testing.T) {
+	k := newWatermarkedKriti()
+
+	// wm.png is in the watermarked source too, it must not be watermarked recursively
+	img, err := k.watermarkedImage(context.Background(), "protected.png")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(1, 1)); got != blue {
+		t.Errorf("Expected watermark color %v, got %v", blue, got)
+	}
+	if got := color.RGBAModel.Convert(img.At(10, 10)); got != red {
+		t.Errorf("Expected image color %v, got %v", red, got)
+	}
+}
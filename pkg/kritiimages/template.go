@@ -0,0 +1,225 @@
+package kritiimages
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"time"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/transformations"
+)
+
+// ErrTemplateNotFound is returned when no template is registered with the requested name
+var ErrTemplateNotFound = errors.New("template not found")
+
+// ErrMissingTemplateSlot is returned when a layer has neither a default nor a slot value
+var ErrMissingTemplateSlot = errors.New("missing template slot")
+
+// Template lays out source images and text over a canvas, e.g. Open Graph cards.
+// Layers are drawn in order, i.e. the last one is on top.
+type Template struct {
+	Width           int
+	Height          int
+	Background      color.Color // canvas color, transparent if nil
+	BackgroundImage string      // image path covering the canvas, drawn over Background
+	Format          string      // output format, png if empty
+	Quality         int         // lossy quality for JPEG & WEBP, 1 to 100
+	Layers          []TemplateLayer
+
+	// Digest identifies the template definition, it must change when the template
+	// is modified so that cached renders and ETags change too.
+	Digest  string
+	ModTime time.Time // last modified time of the template definition
+}
+
+// TemplateLayer is either an image or text, exactly one of Image and Text is set.
+// When Slot is set, value of the slot replaces Image.Path or Text.Content.
+type TemplateLayer struct {
+	Slot  string
+	Image *TemplateImage
+	Text  *Text
+}
+
+// TemplateImage places a source image in a box of the canvas.
+type TemplateImage struct {
+	Path          string // image path in any configured source, URL for http source
+	Fit           string // fit mode in the box e.g. cover, contain; resized image is centered in the box
+	X, Y          int    // top left corner of the box
+	Width, Height int
+}
+
+// Slots returns names of slots of the template.
+func (t *Template) Slots() []string {
+	slots := make([]string, 0, len(t.Layers))
+	for _, layer := range t.Layers {
+		if layer.Slot != "" {
+			slots = append(slots, layer.Slot)
+		}
+	}
+	return slots
+}
+
+// Template returns the template registered in KritiImages.Templates as `name`.
+func (k *KritiImages) Template(name string) (*Template, error) {
+	template, ok := k.Templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return template, nil
+}
+
+// TemplateVersion combines versions of the template and of all images it draws
+// with `slots`, nil if version of any image is unknown.
+func (k *KritiImages) TemplateVersion(ctx context.Context, template *Template, slots map[string]string) (*ImageVersion, error) {
+	layers, err := resolveLayers(template, slots)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(layers)+1)
+	if template.BackgroundImage != "" {
+		paths = append(paths, template.BackgroundImage)
+	}
+	for _, layer := range layers {
+		if layer.Image != nil {
+			paths = append(paths, layer.Image.Path)
+		}
+	}
+
+	combined := &ImageVersion{ModTime: template.ModTime}
+	etags := []string{template.Digest}
+	for _, path := range paths {
+		version, err := k.Version(ctx, path)
+		if err != nil || version == nil {
+			return nil, err
+		}
+		if version.ModTime.After(combined.ModTime) {
+			combined.ModTime = version.ModTime
+		}
+		etags = append(etags, path+"@"+version.ETag)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(etags, "\n")))
+	combined.ETag = hex.EncodeToString(sum[:16])
+	return combined, nil
+}
+
+// Render draws `template` with `slots` values and encodes it in format of the template.
+func (k *KritiImages) Render(ctx context.Context, template *Template, slots map[string]string) (*bytes.Buffer, error) {
+	layers, err := resolveLayers(template, slots)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, template.Width, template.Height))
+	if template.Background != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(template.Background), image.Point{}, draw.Src)
+	}
+	if template.BackgroundImage != "" {
+		background := TemplateImage{Path: template.BackgroundImage, Fit: "cover", Width: template.Width, Height: template.Height}
+		if err := k.drawTemplateImage(ctx, canvas, &background); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, layer := range layers {
+		if layer.Image != nil {
+			if err := k.drawTemplateImage(ctx, canvas, layer.Image); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		filter, err := k.textFilter(layer.Text)
+		if err != nil {
+			return nil, errors.Join(ErrTransformationsNotFound, err)
+		}
+		// text filter copies the source, it can not draw in place
+		dst := image.NewRGBA(canvas.Bounds())
+		filter.Draw(dst, canvas, nil)
+		canvas = dst
+	}
+
+	format, quality := template.Format, template.Quality
+	if format == "" {
+		format = "png"
+	}
+	if quality <= 0 {
+		quality = 100
+	}
+	return k.formatTo(canvas, format, quality)
+}
+
+// drawTemplateImage fits the source image in the box of `layer` and draws it
+// over `canvas`, watermark of the source is applied to the image.
+func (k *KritiImages) drawTemplateImage(ctx context.Context, canvas draw.Image, layer *TemplateImage) error {
+	img, _, err := k.getImageSource(layer.Path).GetImage(ctx, layer.Path)
+	if err != nil {
+		return ErrSourceImageNotFound
+	}
+
+	fit := layer.Fit
+	if fit == "" {
+		fit = "cover"
+	}
+	fitFilter, err := transformations.CreateFitFilter(fit, layer.Width, layer.Height, color.Transparent)
+	if err != nil {
+		return errors.Join(ErrTransformationsNotFound, err)
+	}
+	watermark, err := k.overlayFilters(ctx, layer.Path, nil)
+	if err != nil {
+		return errors.Join(ErrTransformationsNotFound, err)
+	}
+
+	g := gift.New(append([]gift.Filter{fitFilter}, watermark...)...)
+	resized := image.NewRGBA(g.Bounds(img.Bounds()))
+	g.Draw(resized, img)
+
+	// centered in the box when fit mode keeps the image smaller than the box
+	size := resized.Bounds().Size()
+	box := image.Rect(layer.X, layer.Y, layer.X+layer.Width, layer.Y+layer.Height)
+	position := box.Min.Add(image.Pt((layer.Width-size.X)/2, (layer.Height-size.Y)/2))
+	target := image.Rectangle{Min: position, Max: position.Add(size)}.Intersect(box)
+	draw.Draw(canvas, target, resized, resized.Bounds().Min.Add(target.Min.Sub(position)), draw.Over)
+	return nil
+}
+
+// resolveLayers returns copies of template layers with slot values applied.
+func resolveLayers(template *Template, slots map[string]string) ([]TemplateLayer, error) {
+	layers := make([]TemplateLayer, 0, len(template.Layers))
+	for _, layer := range template.Layers {
+		value, hasValue := slots[layer.Slot]
+		hasValue = hasValue && layer.Slot != "" && strings.TrimSpace(value) != ""
+
+		switch {
+		case layer.Image != nil:
+			img := *layer.Image
+			if hasValue {
+				img.Path = value
+			}
+			if img.Path == "" {
+				return nil, fmt.Errorf("%w: %s", ErrMissingTemplateSlot, layer.Slot)
+			}
+			layer.Image = &img
+		case layer.Text != nil:
+			text := *layer.Text
+			if hasValue {
+				text.Content = value
+			}
+			if strings.TrimSpace(text.Content) == "" {
+				return nil, fmt.Errorf("%w: %s", ErrMissingTemplateSlot, layer.Slot)
+			}
+			layer.Text = &text
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
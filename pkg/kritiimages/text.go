@@ -0,0 +1,69 @@
+package kritiimages
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/disintegration/gift"
+	"github.com/kritihq/kriti-images/internal/fonts"
+	"github.com/kritihq/kriti-images/internal/transformations"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// FontLoader provides fonts to draw text with, by family name.
+type FontLoader interface {
+	// Font returns font of `family`, default font if `family` is empty.
+	Font(family string) (*opentype.Font, error)
+}
+
+// bundledFonts is used when KritiImages.Fonts is not set
+var bundledFonts = fonts.New("", "")
+
+// Text is a caption drawn over the transformed image.
+type Text struct {
+	Content     string
+	Font        string // font family, default font of FontLoader if empty
+	Size        int    // in px
+	Color       color.Color
+	Stroke      int // stroke width in px, 0 for no stroke
+	StrokeColor color.Color
+	Background  color.Color // color of box behind the text, nil for no box
+	Padding     int         // space in px between text and edges of the box
+	Gravity     string      // e.g. center, northeast, see utils.ParseGravityValue
+	X, Y        int         // offsets in px from the edges of gravity
+	Width       int         // lines are wrapped to this width in px, 0 wraps to the image width
+}
+
+// textFilter returns filter drawing `text` with font loaded from k.Fonts.
+func (k *KritiImages) textFilter(text *Text) (gift.Filter, error) {
+	loader := k.Fonts
+	if loader == nil {
+		loader = bundledFonts
+	}
+	f, err := loader.Font(text.Font)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(text.Size), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+
+	filter, err := transformations.CreateTextFilter(text.Content, face, transformations.TextOptions{
+		Color:       text.Color,
+		StrokeWidth: text.Stroke,
+		StrokeColor: text.StrokeColor,
+		Background:  text.Background,
+		Padding:     text.Padding,
+		Gravity:     text.Gravity,
+		X:           text.X,
+		Y:           text.Y,
+		Width:       text.Width,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create text filter: %w", err)
+	}
+	return filter, nil
+}
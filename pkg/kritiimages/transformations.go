@@ -3,9 +3,9 @@ package kritiimages
 import (
 	"fmt"
 	"image"
+	"strconv"
 
 	"github.com/disintegration/gift"
-	"github.com/gofiber/fiber/v2/log"
 	"github.com/kritihq/kriti-images/internal/transformations"
 	"github.com/kritihq/kriti-images/internal/utils"
 )
@@ -30,11 +30,72 @@ const (
 	Format
 	Quality
 	BorderRadius
+	DPR // device pixel ratio, multiplies width and height
+	Palette
+	OverlayPath
+	OverlayGravity
+	OverlayX
+	OverlayY
+	OverlayScale
+	OverlayOpacity
+	OverlayTile
+	TextContent
+	TextFont
+	TextSize
+	TextColor
+	TextStroke
+	TextStrokeColor
+	TextBackground
+	TextPadding
+	TextGravity
+	TextX
+	TextY
+	TextWidth
+	Mask
+	Border
+	Padding
+	Shadow
+	Grayscale
+	Sepia
+	Invert
+	Hue
+	Colorize
+	Duotone
+	Pixelate
 )
 
+// filterOrder is the order in which transformations are applied regardless of
+// their order in the request, so that same options always result in the same
+// image: resize first, then orientation, adjustments and shape.
+var filterOrder = []TransformationOption{
+	Fit, Flip, Rotate,
+	Brightness, Contrast, Gamma, Saturation, Hue, Grayscale, Sepia, Colorize, Duotone, Invert,
+	Sharpen, Blur, Pixelate, Padding, BorderRadius,
+}
+
+// decorationOrder is the order of transformations drawn around the final shape
+// of the image, i.e. after masks.
+var decorationOrder = []TransformationOption{Border, Shadow}
+
 func getFilters(options map[TransformationOption]string, destination *DestinationImage) ([]gift.Filter, error) {
 	filters := make([]gift.Filter, 0)
 
+	// redactions are blur and pixelate scoped to regions e.g. blur=20@10,10,50,50,
+	// applied before any other filter since regions are in px of the source image
+	redactions := make([]gift.Filter, 0)
+	addScoped := func(filter gift.Filter, regions []image.Rectangle) error {
+		if len(regions) == 0 {
+			filters = append(filters, filter)
+			return nil
+		}
+		regionFilter, err := transformations.CreateRegionFilter(filter, regions)
+		if err != nil {
+			return err
+		}
+		redactions = append(redactions, regionFilter)
+		return nil
+	}
+
 	// Check if we have dimensions but no fit parameter
 	hasDimensions := destination.Width > 0 || destination.Height > 0
 	_, hasFit := options[Fit]
@@ -50,7 +111,12 @@ func getFilters(options map[TransformationOption]string, destination *Destinatio
 		}
 	}
 
-	for t, values := range options {
+	for _, t := range filterOrder {
+		values, ok := options[t]
+		if !ok {
+			continue
+		}
+
 		switch t {
 		case Flip:
 			switch values {
@@ -62,8 +128,26 @@ func getFilters(options map[TransformationOption]string, destination *Destinatio
 				filters = append(filters, gift.FlipHorizontal(), gift.FlipVertical())
 			}
 		case Blur:
-			strength := utils.ParseFloatValue(values, 1, 250, 1)
-			filters = append(filters, gift.GaussianBlur(strength))
+			strengthStr, regions, err := utils.ParseRegionsValue(values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blur value: %w", err)
+			}
+			strength := utils.ParseFloatValue(strengthStr, 1, 250, 1)
+			if err := addScoped(gift.GaussianBlur(strength), regions); err != nil {
+				return nil, fmt.Errorf("failed to create blur filter: %w", err)
+			}
+		case Pixelate:
+			sizeStr, regions, err := utils.ParseRegionsValue(values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pixelate value: %w", err)
+			}
+			size, err := utils.ParseIntValue(sizeStr, 2, 250)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pixelate size: %w", err)
+			}
+			if err := addScoped(gift.Pixelate(size), regions); err != nil {
+				return nil, fmt.Errorf("failed to create pixelate filter: %w", err)
+			}
 		case Brightness:
 			strengthPct := utils.ParseFloatValue(values, -100, 100, 0)
 			filters = append(filters, gift.Brightness(strengthPct))
@@ -93,6 +177,40 @@ func getFilters(options map[TransformationOption]string, destination *Destinatio
 		case Sharpen:
 			strength := utils.ParseFloatValue(values, 0.5, 1.5, 0.5)
 			filters = append(filters, gift.UnsharpMask(1.0, strength, 0.0))
+		case Grayscale:
+			enabled, err := strconv.ParseBool(values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid grayscale value: must be true or false, got %s", values)
+			}
+			if enabled {
+				filters = append(filters, gift.Grayscale())
+			}
+		case Invert:
+			enabled, err := strconv.ParseBool(values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid invert value: must be true or false, got %s", values)
+			}
+			if enabled {
+				filters = append(filters, gift.Invert())
+			}
+		case Sepia:
+			strengthPct := utils.ParseFloatValue(values, 0, 100, 100)
+			filters = append(filters, gift.Sepia(strengthPct))
+		case Hue:
+			shift := utils.ParseFloatValue(values, -180, 180, 0)
+			filters = append(filters, gift.Hue(shift))
+		case Colorize:
+			colorizeFilter, err := transformations.CreateColorizeFilter(values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create colorize filter: %w", err)
+			}
+			filters = append(filters, colorizeFilter)
+		case Duotone:
+			duotoneFilter, err := transformations.CreateDuotoneFilter(values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create duotone filter: %w", err)
+			}
+			filters = append(filters, duotoneFilter)
 		case BorderRadius:
 			radiusFilter, err := transformations.CreateBorderRadiusFilter(values)
 			if err != nil {
@@ -101,8 +219,41 @@ func getFilters(options map[TransformationOption]string, destination *Destinatio
 			if radiusFilter != nil {
 				filters = append(filters, radiusFilter)
 			}
-		default:
-			log.Warnf("unkonwn transformation option: %v", t)
+		case Padding:
+			paddingFilter, err := transformations.CreatePaddingFilter(values, destination.BgColor)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create padding filter: %w", err)
+			}
+			filters = append(filters, paddingFilter)
+		}
+	}
+
+	return append(redactions, filters...), nil
+}
+
+// getDecorationFilters returns filters of borders and shadows, they follow alpha
+// of the image so must be applied after shape transformations e.g. radius and mask.
+func getDecorationFilters(options map[TransformationOption]string) ([]gift.Filter, error) {
+	filters := make([]gift.Filter, 0)
+	for _, t := range decorationOrder {
+		values, ok := options[t]
+		if !ok {
+			continue
+		}
+
+		switch t {
+		case Border:
+			borderFilter, err := transformations.CreateBorderFilter(values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create border filter: %w", err)
+			}
+			filters = append(filters, borderFilter)
+		case Shadow:
+			shadowFilter, err := transformations.CreateShadowFilter(values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create shadow filter: %w", err)
+			}
+			filters = append(filters, shadowFilter)
 		}
 	}
 